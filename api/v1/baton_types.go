@@ -16,7 +16,10 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -24,10 +27,102 @@ import (
 
 // BatonSpec defines the desired state of Baton
 type BatonSpec struct {
-	Deployment        `json:"deployment"`
-	Strategies        []Strategy `json:"strategies"`
-	IntervalSec       int32      `json:"intervalSec"`
-	MonitorTimeoutSec int32      `json:"monitorTimeoutSec"`
+	// Deployment is the target of Baton when WorkloadRef is not set.
+	// Deprecated: use WorkloadRef instead.
+	// +optional
+	*Deployment `json:"deployment,omitempty"`
+	// WorkloadRef is the scalable workload whose pods are rebalanced, e.g. a Deployment, StatefulSet or Argo Rollout
+	// +optional
+	WorkloadRef       *WorkloadRef `json:"workloadRef,omitempty"`
+	Strategies        []Strategy   `json:"strategies"`
+	IntervalSec       int32        `json:"intervalSec"`
+	MonitorTimeoutSec int32        `json:"monitorTimeoutSec"`
+	// MigrationMode decides whether a pod is evicted before (Delete) or after (SurgeFirst) its replacement is scheduled
+	// +kubebuilder:validation:Enum=Delete;SurgeFirst
+	MigrationMode MigrationMode `json:"migrationMode,omitempty"`
+	// SteeringMethod decides how pods are kept off the nodes they are migrated from: Cordon marks the nodes unschedulable,
+	// Taint applies a NoSchedule taint unique to the Baton so only the pods of its workload are kept off,
+	// and NodeAffinity leaves the nodes untouched and injects a required node affinity into the replacement pods
+	// +kubebuilder:validation:Enum=Cordon;Taint;NodeAffinity
+	// +optional
+	SteeringMethod SteeringMethod `json:"steeringMethod,omitempty"`
+	// FailurePolicy decides what happens when a pod fails to migrate: Continue with the next pod,
+	// Abort the remaining migrations, or Rollback by also uncordoning the source nodes right away
+	// +kubebuilder:validation:Enum=Abort;Rollback;Continue
+	// +optional
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+	// MaxConcurrentMigrations is the number of pods migrated at once, as an absolute number
+	// or a percentage of the replicas rounded down. Each batch has to be ready before the next one starts.
+	// Defaults to 1.
+	// +optional
+	MaxConcurrentMigrations *intstr.IntOrString `json:"maxConcurrentMigrations,omitempty"`
+	// MigrationsPerMinute limits how many pods are evicted per minute across batches and runs.
+	// 0 means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MigrationsPerMinute int32 `json:"migrationsPerMinute,omitempty"`
+	// Schedule restricts cordoning nodes and evicting pods to its windows. The strategies are observed at any time.
+	// +optional
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Evacuation moves the pods off the nodes of a strategy which signal their termination right away,
+	// without waiting for the interval or the schedule. It is disabled when not set.
+	// +optional
+	Evacuation *Evacuation `json:"evacuation,omitempty"`
+	// DryRun publishes the migrations each run would make in the status and Events without cordoning nodes or evicting pods
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// Suspend stops the runner after the migration in progress until it is set back to false.
+	// The pause annotation suspends the Baton as well.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// PauseAnnotation suspends the Baton when set to "true", e.g. with kubectl annotate during an incident
+const PauseAnnotation = "baton.baton/pause"
+
+type MigrationMode string
+
+const (
+	// MigrationModeDelete evicts a pod and then waits for the Deployment to replace it
+	MigrationModeDelete MigrationMode = "Delete"
+	// MigrationModeSurgeFirst temporarily adds a replica and evicts a pod once the added one is scheduled
+	MigrationModeSurgeFirst MigrationMode = "SurgeFirst"
+)
+
+type SteeringMethod string
+
+const (
+	// SteeringMethodCordon cordons the nodes, which keeps every new pod off them
+	SteeringMethodCordon SteeringMethod = "Cordon"
+	// SteeringMethodTaint taints the nodes with a key which the pods of the workload do not tolerate
+	SteeringMethodTaint SteeringMethod = "Taint"
+	// SteeringMethodNodeAffinity injects a node affinity for the target nodes into the new pods of the workload
+	SteeringMethodNodeAffinity SteeringMethod = "NodeAffinity"
+)
+
+type FailurePolicy string
+
+const (
+	// FailurePolicyAbort stops the remaining migrations of the run
+	FailurePolicyAbort FailurePolicy = "Abort"
+	// FailurePolicyRollback stops the remaining migrations and uncordons the source nodes so the pod can return
+	FailurePolicyRollback FailurePolicy = "Rollback"
+	// FailurePolicyContinue migrates the remaining pods anyway
+	FailurePolicyContinue FailurePolicy = "Continue"
+)
+
+// Evacuation is the set of signals which tell that a node is about to be terminated.
+// A node being deleted or tainted with ToBeDeletedByClusterAutoscaler is always terminating.
+type Evacuation struct {
+	// Taints are the keys of taints which mark a terminating node, e.g. the one set by a node termination handler
+	// +optional
+	Taints []string `json:"taints,omitempty"`
+	// Annotations are the keys of annotations which mark a terminating node
+	// +optional
+	Annotations []string `json:"annotations,omitempty"`
+	// Conditions are the types of node conditions which mark a terminating node when True
+	// +optional
+	Conditions []corev1.NodeConditionType `json:"conditions,omitempty"`
 }
 
 type Deployment struct {
@@ -35,13 +130,98 @@ type Deployment struct {
 	NameSpace string `json:"namespace"`
 }
 
+type WorkloadRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Namespace defaults to the namespace of the Baton
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GroupVersionKind returns the GroupVersionKind of the referenced workload
+func (r WorkloadRef) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind)
+}
+
 // BatonStatus defines the observed state of Baton
 type BatonStatus struct {
+	// ObservedGeneration is the generation of the spec the runner applied
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastRunStartedAt and LastSuccessfulRunAt keep their snake_case names for existing clients, newer fields are camelCase
 	LastRunStartedAt    string `json:"last_run_started_at"`
 	LastSuccessfulRunAt string `json:"last_successful_run_at"`
+	// LastError is the reason the last run could not finish, e.g. an eviction blocked by PodDisruptionBudget
+	LastError  string           `json:"lastError,omitempty"`
+	Conditions []BatonCondition `json:"conditions,omitempty"`
+	Strategies []StrategyStatus `json:"strategies,omitempty"`
+	// Plan is the migrations planned by the last run, which were not carried out in dry run
+	Plan []MigrationPlan `json:"plan,omitempty"`
+	// LastAbortedMigration is the last migration stopped by the failure policy
+	LastAbortedMigration *AbortedMigration `json:"lastAbortedMigration,omitempty"`
+	// Steering is set while the new pods of the workload are steered by node affinity
+	Steering *Steering `json:"steering,omitempty"`
+}
+
+// StrategyStatus is the observed state of a strategy at the end of the last run
+type StrategyStatus struct {
+	NodeMatchLabels map[string]string `json:"nodeMatchLabels,omitempty"`
+	// NodeSelector is the merged node selector of the strategy in its string form
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	MatchedNodes int32  `json:"matchedNodes"`
+	CurrentPods  int32  `json:"currentPods"`
+	KeepPods     int32  `json:"keepPods,omitempty"`
+	// Weight is the weight of the strategy, whose share of the replicas is KeepPods
+	Weight int32 `json:"weight,omitempty"`
+	// Rest is true for a strategy without keepPods nor weight, which takes the pods the others do not keep
+	Rest bool `json:"rest,omitempty"`
+	// PodsPerDomain is the number of pods in each domain of the topologyKey of the strategy
+	PodsPerDomain map[string]int32 `json:"podsPerDomain,omitempty"`
+}
+
+// MigrationPlan is a set of pods Baton moves off the nodes of a strategy, or onto them
+type MigrationPlan struct {
+	// Strategy is the node selector of the strategy which has suplus or less pods
+	Strategy string `json:"strategy"`
+	// Type is Suplus when pods are moved off the strategy's nodes, and Less when they are moved onto them
+	Type        string   `json:"type"`
+	CordonNodes []string `json:"cordonNodes,omitempty"`
+	EvictPods   []string `json:"evictPods,omitempty"`
+	// TargetNodes are the nodes the evicted pods can be scheduled to
+	TargetNodes []string `json:"targetNodes,omitempty"`
+}
+
+// AbortedMigration is a migration stopped because a pod failed to migrate
+type AbortedMigration struct {
+	Strategy string `json:"strategy"`
+	Type     string `json:"type"`
+	// Pod is the pod which failed to migrate
+	Pod       string `json:"pod"`
+	Reason    string `json:"reason"`
+	AbortedAt string `json:"abortedAt"`
+	// RolledBack is true when the source nodes were uncordoned and the pod was ready again
+	RolledBack bool `json:"rolledBack"`
+}
+
+// Steering is the required node affinity injected into the new pods of the workload during a migration
+type Steering struct {
+	// PodSelector selects the pods of the workload in the namespace of the workload
+	PodSelector string `json:"podSelector"`
+	Namespace   string `json:"namespace"`
+	// NodeSelectorTerms select the nodes of the target strategies, and are ORed as in a node affinity
+	NodeSelectorTerms []corev1.NodeSelectorTerm `json:"nodeSelectorTerms"`
+	StartedAt         string                    `json:"startedAt"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Deployment",type=string,JSONPath=`.spec.deployment.name`
+// +kubebuilder:printcolumn:name="Workload",type=string,JSONPath=`.spec.workloadRef.name`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Suspended",type=string,JSONPath=`.status.conditions[?(@.type=="Suspended")].status`
+// +kubebuilder:printcolumn:name="Last Successful Run",type=string,JSONPath=`.status.last_successful_run_at`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Baton is the Schema for the batons API
 type Baton struct {
@@ -61,6 +241,29 @@ type BatonList struct {
 	Items           []Baton `json:"items"`
 }
 
+// GetWorkloadRef returns the target of the Baton, falling back to the deprecated Deployment field
+func (r *Baton) GetWorkloadRef() WorkloadRef {
+	if r.Spec.WorkloadRef != nil {
+		workloadRef := *r.Spec.WorkloadRef
+		if workloadRef.Namespace == "" {
+			workloadRef.Namespace = r.ObjectMeta.Namespace
+		}
+		return workloadRef
+	}
+
+	workloadRef := WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment"}
+	if r.Spec.Deployment != nil {
+		workloadRef.Name = r.Spec.Deployment.Name
+		workloadRef.Namespace = r.Spec.Deployment.NameSpace
+	}
+	return workloadRef
+}
+
+// IsSuspended reports whether the Baton is suspended by its spec or the pause annotation
+func (r *Baton) IsSuspended() bool {
+	return r.Spec.Suspend || r.ObjectMeta.Annotations[PauseAnnotation] == "true"
+}
+
 func init() {
 	SchemeBuilder.Register(&Baton{}, &BatonList{})
 }
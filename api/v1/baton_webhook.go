@@ -0,0 +1,348 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/scale"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+const (
+	defaultIntervalSec       = 60
+	defaultMonitorTimeoutSec = 300
+)
+
+// batonlog is for logging in this package.
+var batonlog = logf.Log.WithName("baton-resource")
+
+// the webhook looks up the workload, nodes and other Batons through the manager while validating
+var (
+	webhookClient client.Client
+	webhookMapper meta.RESTMapper
+	webhookScales scale.ScalesGetter
+)
+
+func (r *Baton) SetupWebhookWithManager(mgr ctrl.Manager, scales scale.ScalesGetter) error {
+	webhookClient = mgr.GetClient()
+	webhookMapper = mgr.GetRESTMapper()
+	webhookScales = scales
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-baton-baton-v1-baton,mutating=true,failurePolicy=fail,groups=baton.baton,resources=batons,verbs=create;update,versions=v1,name=mbaton.kb.io
+
+var _ webhook.Defaulter = &Baton{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Baton) Default() {
+	batonlog.Info("default", "name", r.Name)
+
+	if r.Spec.IntervalSec == 0 {
+		r.Spec.IntervalSec = defaultIntervalSec
+	}
+	if r.Spec.MonitorTimeoutSec == 0 {
+		r.Spec.MonitorTimeoutSec = defaultMonitorTimeoutSec
+	}
+	if r.Spec.MigrationMode == "" {
+		r.Spec.MigrationMode = MigrationModeDelete
+	}
+	if r.Spec.SteeringMethod == "" {
+		r.Spec.SteeringMethod = SteeringMethodCordon
+	}
+	if r.Spec.FailurePolicy == "" {
+		r.Spec.FailurePolicy = FailurePolicyContinue
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-baton-baton-v1-baton,mutating=false,failurePolicy=fail,groups=baton.baton,resources=batons,versions=v1,name=vbaton.kb.io
+
+var _ webhook.Validator = &Baton{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Baton) ValidateCreate() error {
+	batonlog.Info("validate create", "name", r.Name)
+	return r.validateBaton()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Baton) ValidateUpdate(old runtime.Object) error {
+	batonlog.Info("validate update", "name", r.Name)
+
+	// a Baton being deleted must be able to drop its finalizer even when its workload is already gone,
+	// and updates of metadata only, e.g. the pause annotation, must not depend on the state of the cluster
+	if r.ObjectMeta.DeletionTimestamp != nil {
+		return nil
+	}
+	if oldBaton, ok := old.(*Baton); ok && equality.Semantic.DeepEqual(r.Spec, oldBaton.Spec) {
+		return nil
+	}
+	return r.validateBaton()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Baton) ValidateDelete() error {
+	return nil
+}
+
+func (r *Baton) validateBaton() error {
+	ctx := context.Background()
+	allErrs := r.validateSpec()
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, r.validateWorkload(ctx)...)
+	}
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, r.validateNodes(ctx)...)
+	}
+	if len(allErrs) != 0 {
+		return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Baton"}, r.Name, allErrs)
+	}
+
+	return nil
+}
+
+// validateSpec checks the fields which are invalid regardless of the cluster
+func (r *Baton) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.WorkloadRef != nil {
+		workloadRefPath := specPath.Child("workloadRef")
+		if r.Spec.WorkloadRef.APIVersion == "" {
+			allErrs = append(allErrs, field.Required(workloadRefPath.Child("apiVersion"), ""))
+		}
+		if r.Spec.WorkloadRef.Kind == "" {
+			allErrs = append(allErrs, field.Required(workloadRefPath.Child("kind"), ""))
+		}
+		if r.Spec.WorkloadRef.Name == "" {
+			allErrs = append(allErrs, field.Required(workloadRefPath.Child("name"), ""))
+		}
+	} else if r.Spec.Deployment == nil || r.Spec.Deployment.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("workloadRef"), "either workloadRef or deployment must be set"))
+	}
+
+	if r.Spec.IntervalSec <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("intervalSec"), r.Spec.IntervalSec, "must be greater than 0"))
+	}
+	if r.Spec.MonitorTimeoutSec <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("monitorTimeoutSec"), r.Spec.MonitorTimeoutSec, "must be greater than 0"))
+	}
+
+	if r.Spec.MaxConcurrentMigrations != nil {
+		maxConcurrentMigrations, err := intstr.GetValueFromIntOrPercent(r.Spec.MaxConcurrentMigrations, 100, true)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("maxConcurrentMigrations"), r.Spec.MaxConcurrentMigrations.String(), err.Error()))
+		} else if maxConcurrentMigrations <= 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("maxConcurrentMigrations"), r.Spec.MaxConcurrentMigrations.String(), "must be greater than 0"))
+		}
+	}
+	if r.Spec.MigrationsPerMinute < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("migrationsPerMinute"), r.Spec.MigrationsPerMinute, "must not be negative"))
+	}
+
+	if r.Spec.Schedule != nil {
+		schedulePath := specPath.Child("schedule")
+		for i, window := range r.Spec.Schedule.Windows {
+			if err := window.Validate(); err != nil {
+				allErrs = append(allErrs, field.Invalid(schedulePath.Child("windows").Index(i), window, err.Error()))
+			}
+		}
+		for i, blackout := range r.Spec.Schedule.Blackouts {
+			if err := blackout.Validate(); err != nil {
+				allErrs = append(allErrs, field.Invalid(schedulePath.Child("blackouts").Index(i), blackout, err.Error()))
+			}
+		}
+	}
+
+	strategiesPath := specPath.Child("strategies")
+	isWeighted := HasWeightedStrategies(r.Spec.Strategies)
+	if len(r.Spec.Strategies) == 0 {
+		allErrs = append(allErrs, field.Required(strategiesPath, "at least one strategy is required"))
+	}
+	selectors := map[string]int{}
+	for i, strategy := range r.Spec.Strategies {
+		strategyPath := strategiesPath.Index(i)
+		selector, err := strategy.Selector()
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("nodeSelector"), strategy.NodeSelector, err.Error()))
+			continue
+		}
+		if selector.Empty() {
+			allErrs = append(allErrs, field.Required(strategyPath.Child("nodeMatchLabels"), "a strategy must select nodes by nodeMatchLabels or nodeSelector"))
+			continue
+		}
+		if j, isDuplicated := selectors[selector.String()]; isDuplicated {
+			allErrs = append(allErrs, field.Duplicate(strategyPath.Child("nodeMatchLabels"), fmt.Sprintf("same nodes as strategies[%d]", j)))
+			continue
+		}
+		selectors[selector.String()] = i
+
+		for j, taint := range strategy.ExcludeTaints {
+			if taint.Key == "" {
+				allErrs = append(allErrs, field.Required(strategyPath.Child("excludeTaints").Index(j).Child("key"), "a taint is selected by its key"))
+			}
+		}
+		if _, err := strategy.ResolveKeepPods(0); err != nil {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("keepPods"), strategy.KeepPods.String(), err.Error()))
+		}
+		// the weighted strategies take the replicas a strategy without keepPods would share, leaving it none
+		if isWeighted && strategy.IsRest() {
+			allErrs = append(allErrs, field.Required(strategyPath.Child("keepPods"), "keepPods or weight is required when other strategies have a weight"))
+		}
+		if strategy.MinPods != nil && *strategy.MinPods < 0 {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("minPods"), *strategy.MinPods, "must not be negative"))
+		}
+		// maxPods of 0 would silently turn any keepPods into keeping no pods
+		if strategy.MaxPods != nil && *strategy.MaxPods < 1 {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("maxPods"), *strategy.MaxPods, "must be greater than 0"))
+		}
+		if strategy.IsRest() && (strategy.MinPods != nil || strategy.MaxPods != nil) {
+			allErrs = append(allErrs, field.Forbidden(strategyPath.Child("keepPods"), "minPods and maxPods only bound keepPods and require it"))
+		}
+		if strategy.Weight != nil {
+			weightPath := strategyPath.Child("weight")
+			if *strategy.Weight < 1 {
+				allErrs = append(allErrs, field.Invalid(weightPath, *strategy.Weight, "must be greater than 0"))
+			}
+			if strategy.KeepPods != (intstr.IntOrString{}) {
+				allErrs = append(allErrs, field.Forbidden(weightPath, "keepPods and weight are mutually exclusive"))
+			}
+			if strategy.MinPods != nil || strategy.MaxPods != nil {
+				allErrs = append(allErrs, field.Forbidden(weightPath, "minPods and maxPods only bound keepPods and can not be used with weight"))
+			}
+		}
+		if strategy.TopologyKey != "" {
+			for _, msg := range validation.IsQualifiedName(strategy.TopologyKey) {
+				allErrs = append(allErrs, field.Invalid(strategyPath.Child("topologyKey"), strategy.TopologyKey, msg))
+			}
+		} else if strategy.MaxSkew != nil {
+			allErrs = append(allErrs, field.Required(strategyPath.Child("topologyKey"), "maxSkew requires topologyKey"))
+		}
+		if strategy.MaxSkew != nil && *strategy.MaxSkew < 1 {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("maxSkew"), *strategy.MaxSkew, "must be greater than 0"))
+		}
+	}
+	return allErrs
+}
+
+// validateWorkload checks the workload exists and keepPods fit its replicas
+func (r *Baton) validateWorkload(ctx context.Context) field.ErrorList {
+	var allErrs field.ErrorList
+	if webhookClient == nil {
+		return allErrs
+	}
+	specPath := field.NewPath("spec")
+
+	workloadRef := r.GetWorkloadRef()
+	workload, err := k8s.GetWorkload(
+		ctx,
+		webhookClient,
+		webhookMapper,
+		webhookScales,
+		workloadRef.GroupVersionKind(),
+		workloadRef.Namespace,
+		workloadRef.Name,
+	)
+	if err != nil {
+		workloadPath := specPath.Child("workloadRef")
+		if r.Spec.WorkloadRef == nil {
+			workloadPath = specPath.Child("deployment")
+		}
+		return append(allErrs, field.Invalid(workloadPath, workloadRef.Name, err.Error()))
+	}
+
+	strategies, err := ResolveStrategies(r.Spec.Strategies, workload.Replicas)
+	if err != nil {
+		return append(allErrs, field.Invalid(specPath.Child("strategies"), workload.Replicas, err.Error()))
+	}
+	if totalKeepPods := GetTotalKeepPods(strategies); int32(totalKeepPods) > workload.Replicas {
+		allErrs = append(allErrs, field.Invalid(
+			specPath.Child("strategies"),
+			totalKeepPods,
+			fmt.Sprintf("the sum of keepPods must not exceed the replicas of %s (%d)", workload, workload.Replicas),
+		))
+	}
+
+	if HasWeightedStrategies(strategies) {
+		for i, strategy := range strategies {
+			if strategy.IsRest() {
+				allErrs = append(allErrs, field.Invalid(
+					specPath.Child("strategies").Index(i).Child("keepPods"),
+					strategy.KeepPods.String(),
+					fmt.Sprintf("keepPods resolves to 0 for the replicas of %s (%d), which leaves the strategy no pods next to weighted strategies", workload, workload.Replicas),
+				))
+			}
+		}
+		fixedStrategies := FilterStrategies(strategies, func(s Strategy) bool { return s.Weight == nil })
+		if fixedPods := GetTotalKeepPods(fixedStrategies); int32(fixedPods) >= workload.Replicas {
+			allErrs = append(allErrs, field.Invalid(
+				specPath.Child("strategies"),
+				fixedPods,
+				fmt.Sprintf("the sum of keepPods leaves none of the replicas of %s (%d) to the weighted strategies", workload, workload.Replicas),
+			))
+		}
+	}
+	return allErrs
+}
+
+// validateNodes checks no node is selected by more than one strategy, since its pods would count for both
+func (r *Baton) validateNodes(ctx context.Context) field.ErrorList {
+	var allErrs field.ErrorList
+	if webhookClient == nil {
+		return allErrs
+	}
+	strategiesPath := field.NewPath("spec").Child("strategies")
+
+	nodes, err := k8s.ListNodeMatchSelector(ctx, webhookClient, labels.Everything())
+	if err != nil {
+		return append(allErrs, field.InternalError(strategiesPath, err))
+	}
+	for _, node := range nodes {
+		matchedStrategy := -1
+		for i, strategy := range r.Spec.Strategies {
+			selector, err := strategy.Selector()
+			if err != nil || !selector.Matches(labels.Set(node.ObjectMeta.Labels)) {
+				continue
+			}
+			if matchedStrategy != -1 {
+				return append(allErrs, field.Invalid(
+					strategiesPath.Index(i),
+					strategy.String(),
+					fmt.Sprintf("Node %s is also selected by strategies[%d]", node.ObjectMeta.Name, matchedStrategy),
+				))
+			}
+			matchedStrategy = i
+		}
+	}
+	return allErrs
+}
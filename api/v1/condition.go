@@ -0,0 +1,99 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type BatonConditionType string
+
+const (
+	// ConditionReady is true when every strategy keeps the number of pods it asks for
+	ConditionReady BatonConditionType = "Ready"
+	// ConditionProgressing is true while the runner is running the strategies
+	ConditionProgressing BatonConditionType = "Progressing"
+	// ConditionDegraded is true when the last run could not migrate pods as planned
+	ConditionDegraded BatonConditionType = "Degraded"
+	// ConditionValidationFailed is true when the strategies do not fit the Deployment
+	ConditionValidationFailed BatonConditionType = "ValidationFailed"
+	// ConditionSuspended is true while the Baton is suspended by spec.suspend or the pause annotation
+	ConditionSuspended BatonConditionType = "Suspended"
+	// ConditionInWindow is true while spec.schedule allows migrations, and is only set with a schedule
+	ConditionInWindow BatonConditionType = "InWindow"
+)
+
+// BatonCondition follows the shape of the upstream metav1.Condition
+type BatonCondition struct {
+	Type               BatonConditionType     `json:"type"`
+	Status             metav1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime"`
+	Reason             string                 `json:"reason"`
+	Message            string                 `json:"message"`
+}
+
+// SetCondition adds or updates the condition of the given type.
+// LastTransitionTime only changes when the status of the condition changes.
+func SetCondition(
+	conditions *[]BatonCondition,
+	conditionType BatonConditionType,
+	status metav1.ConditionStatus,
+	reason string,
+	message string,
+) {
+	for i := range *conditions {
+		condition := &(*conditions)[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Status = status
+		condition.Reason = reason
+		condition.Message = message
+		return
+	}
+
+	*conditions = append(*conditions, BatonCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// FindCondition returns the condition of the given type, or nil if it is not set
+func FindCondition(conditions []BatonCondition, conditionType BatonConditionType) *BatonCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// RemoveCondition removes the condition of the given type if it is set
+func RemoveCondition(conditions *[]BatonCondition, conditionType BatonConditionType) {
+	filtered := []BatonCondition{}
+	for _, condition := range *conditions {
+		if condition.Type != conditionType {
+			filtered = append(filtered, condition)
+		}
+	}
+	*conditions = filtered
+}
@@ -0,0 +1,275 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxWindowDuration bounds how far back a window is looked up from the current time
+const maxWindowDuration = 31 * 24 * time.Hour
+
+// Schedule restricts when Baton may cordon nodes and evict pods.
+// Outside of it the runner still observes the strategies and plans the migrations.
+type Schedule struct {
+	// Windows are the periods migrations are allowed in. Migrations are allowed at any time when there is none.
+	// +optional
+	Windows []ScheduleWindow `json:"windows,omitempty"`
+	// Blackouts are the periods migrations are never allowed in, even inside a window
+	// +optional
+	Blackouts []ScheduleWindow `json:"blackouts,omitempty"`
+}
+
+// ScheduleWindow is a period which opens at every time matching the cron expression and lasts for the duration
+type ScheduleWindow struct {
+	// Cron is a 5-field cron expression: minute, hour, day of month, month and day of week, e.g. "0 22 * * 1-5"
+	Cron string `json:"cron"`
+	// Duration is how long the window stays open, e.g. "4h"
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone is the IANA name of the time zone the cron expression is evaluated in, e.g. "Asia/Tokyo". Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// CompiledSchedule is a Schedule with its cron expressions parsed and its time zones loaded,
+// so that it can be evaluated before every batch of migrations
+type CompiledSchedule struct {
+	windows   []compiledWindow
+	blackouts []compiledWindow
+}
+
+type compiledWindow struct {
+	cron     cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+// Compile parses the windows and blackouts of the schedule
+func (r Schedule) Compile() (*CompiledSchedule, error) {
+	compiled := &CompiledSchedule{}
+	for _, window := range r.Windows {
+		w, err := window.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled.windows = append(compiled.windows, w)
+	}
+	for _, blackout := range r.Blackouts {
+		b, err := blackout.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled.blackouts = append(compiled.blackouts, b)
+	}
+	return compiled, nil
+}
+
+// IsAllowed reports whether migrations are allowed at t
+func (r *CompiledSchedule) IsAllowed(t time.Time) bool {
+	for _, blackout := range r.blackouts {
+		if blackout.isActive(t) {
+			return false
+		}
+	}
+
+	if len(r.windows) == 0 {
+		return true
+	}
+	for _, window := range r.windows {
+		if window.isActive(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// isActive reports whether t is in the window, i.e. the cron expression matches a minute within the duration before t
+func (r compiledWindow) isActive(t time.Time) bool {
+	opened, ok := r.cron.prev(t.In(r.location), t.Add(-r.duration))
+	return ok && t.Sub(opened) < r.duration
+}
+
+// Validate checks the cron expression, the duration and the time zone of the window
+func (r ScheduleWindow) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+func (r ScheduleWindow) compile() (compiledWindow, error) {
+	cron, err := parseCron(r.Cron)
+	if err != nil {
+		return compiledWindow{}, fmt.Errorf("invalid cron %q: %w", r.Cron, err)
+	}
+	if r.Duration.Duration <= 0 || r.Duration.Duration > maxWindowDuration {
+		return compiledWindow{}, fmt.Errorf("duration must be greater than 0 and at most %s", maxWindowDuration)
+	}
+	location, err := r.location()
+	if err != nil {
+		return compiledWindow{}, fmt.Errorf("invalid time zone %q: %w", r.TimeZone, err)
+	}
+	return compiledWindow{cron: cron, duration: r.Duration.Duration, location: location}, nil
+}
+
+func (r ScheduleWindow) location() (*time.Location, error) {
+	if r.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(r.TimeZone)
+}
+
+// cronSchedule holds the values each field of a cron expression matches as bits
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// as in cron, a day matches when both day fields match if either is "*", and when either matches otherwise
+	anyDay bool
+}
+
+var cronFields = []struct {
+	name string
+	min  int
+	max  int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// both 0 and 7 are Sunday
+	{"day of week", 0, 7},
+}
+
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return cronSchedule{}, fmt.Errorf("expected %d fields but got %d", len(cronFields), len(fields))
+	}
+
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		bits, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("%s: %w", cronFields[i].name, err)
+		}
+		values[i] = bits
+	}
+
+	daysOfWeek := values[4]
+	if daysOfWeek&(1<<7) != 0 {
+		daysOfWeek = daysOfWeek&^(1<<7) | 1
+	}
+	return cronSchedule{
+		minutes:     values[0],
+		hours:       values[1],
+		daysOfMonth: values[2],
+		months:      values[3],
+		daysOfWeek:  daysOfWeek,
+		anyDay:      strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of "*", values and ranges, each with an optional "/step"
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		hasStep := false
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step, hasStep = part[:i], s, true
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			l, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			h, err := strconv.Atoi(bounds[1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			low, high = l, h
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			low, high = v, v
+			if hasStep {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (r cronSchedule) matches(t time.Time) bool {
+	return r.minutes&(1<<uint(t.Minute())) != 0 && r.hours&(1<<uint(t.Hour())) != 0 && r.matchesDay(t)
+}
+
+// matchesDay reports whether the month and the day of t match
+func (r cronSchedule) matchesDay(t time.Time) bool {
+	if r.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayOfMonth := r.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := r.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if r.anyDay {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// prev returns the latest minute at or before t the cron expression matches, looking back no further than earliest.
+// It skips the hours whose day or hour does not match at once, and steps back from the start of an hour by a minute,
+// which always lands on a wall clock time existing in the location of t, even across DST changes.
+func (r cronSchedule) prev(t time.Time, earliest time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for !t.Before(earliest) {
+		if r.matchesDay(t) && r.hours&(1<<uint(t.Hour())) != 0 {
+			// the minutes of the hour up to t
+			minutes := r.minutes & (1<<uint(t.Minute()+1) - 1)
+			if minutes != 0 {
+				return t.Add(-time.Duration(t.Minute()-(bits.Len64(minutes)-1)) * time.Minute), true
+			}
+		}
+		t = t.Add(-time.Duration(t.Minute()+1) * time.Minute)
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,279 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func bitsOf(values ...int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+func TestParseCronField(t *testing.T) {
+	cases := []struct {
+		field string
+		min   int
+		max   int
+		want  uint64
+	}{
+		{"*", 0, 5, bitsOf(0, 1, 2, 3, 4, 5)},
+		{"3", 0, 59, bitsOf(3)},
+		{"1-5", 0, 7, bitsOf(1, 2, 3, 4, 5)},
+		{"1,3,5-6", 0, 7, bitsOf(1, 3, 5, 6)},
+		{"*/15", 0, 59, bitsOf(0, 15, 30, 45)},
+		{"10-30/10", 0, 59, bitsOf(10, 20, 30)},
+		// a value with a step runs up to the end of the field
+		{"5/20", 0, 59, bitsOf(5, 25, 45)},
+		{"*/2", 1, 12, bitsOf(1, 3, 5, 7, 9, 11)},
+	}
+	for _, c := range cases {
+		got, err := parseCronField(c.field, c.min, c.max)
+		if err != nil {
+			t.Errorf("parseCronField(%q) failed: %v", c.field, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", c.field, got, c.want)
+		}
+	}
+
+	for _, field := range []string{"", "60", "5-1", "1-", "a", "*/0", "*/-1", "1-70"} {
+		if _, err := parseCronField(field, 0, 59); err == nil {
+			t.Errorf("parseCronField(%q) succeeded, want an error", field)
+		}
+	}
+}
+
+func TestParseCronRejectsWrongNumberOfFields(t *testing.T) {
+	for _, expr := range []string{"", "0 22 * *", "0 22 * * 1 2021"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronSundayIsZeroOrSeven(t *testing.T) {
+	// 2021-01-03 is a Sunday
+	sunday := time.Date(2021, 1, 3, 10, 0, 0, 0, time.UTC)
+	for _, expr := range []string{"0 10 * * 0", "0 10 * * 7", "0 10 * * 6-7", "0 10 * * 5,7"} {
+		cron, err := parseCron(expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q) failed: %v", expr, err)
+		}
+		if !cron.matches(sunday) {
+			t.Errorf("%q does not match Sunday", expr)
+		}
+		if cron.matches(sunday.AddDate(0, 0, 1)) {
+			t.Errorf("%q matches Monday", expr)
+		}
+	}
+}
+
+func TestCronDayOfMonthOrDayOfWeek(t *testing.T) {
+	// 2021-02-01 and 2021-02-08 are Mondays, 2021-02-02 and 2021-06-01 Tuesdays
+	first := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	tuesday := time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC)
+	firstTuesday := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		// either day field matches when both are restricted
+		{"0 0 1 * 1", monday, true},
+		{"0 0 1 * 1", firstTuesday, true},
+		{"0 0 1 * 1", tuesday, false},
+		// only the restricted field counts when the other is "*"
+		{"0 0 1 * *", monday, false},
+		{"0 0 1 * *", first, true},
+		{"0 0 * * 1", firstTuesday, false},
+		{"0 0 * * 1", monday, true},
+		// as in cron, a field starting with "*" counts as "*" even with a step
+		{"0 0 */7 * *", time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC), true},
+		{"0 0 */7 * 2", tuesday, false},
+		// the month is required in any case
+		{"0 0 1 3 1", monday, false},
+	}
+	for _, c := range cases {
+		cron, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q) failed: %v", c.expr, err)
+		}
+		if got := cron.matches(c.t); got != c.want {
+			t.Errorf("%q matches %s = %t, want %t", c.expr, c.t.Format("Mon 2006-01-02"), got, c.want)
+		}
+	}
+}
+
+func newTestWindow(t *testing.T, cron string, duration time.Duration, timeZone string) compiledWindow {
+	window, err := ScheduleWindow{Cron: cron, Duration: metav1.Duration{Duration: duration}, TimeZone: timeZone}.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return window
+}
+
+func TestWindowIsActive(t *testing.T) {
+	// weekdays from 22:00 for 4 hours in Tokyo, i.e. from 13:00 UTC
+	window := newTestWindow(t, "0 22 * * 1-5", 4*time.Hour, "Asia/Tokyo")
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		// Monday 2021-02-01
+		{time.Date(2021, 2, 1, 12, 59, 0, 0, time.UTC), false},
+		{time.Date(2021, 2, 1, 13, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, 2, 1, 16, 59, 59, 0, time.UTC), true},
+		{time.Date(2021, 2, 1, 17, 0, 0, 0, time.UTC), false},
+		// Friday 22:00 opens a window into Saturday, and no window opens on Saturday
+		{time.Date(2021, 2, 5, 15, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, 2, 6, 13, 30, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		if got := window.isActive(c.t); got != c.want {
+			t.Errorf("isActive(%s) = %t, want %t", c.t, got, c.want)
+		}
+	}
+}
+
+func TestWindowIsActiveAcrossDST(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("time zone database is not available")
+	}
+
+	// 2021-03-14 02:00 EST jumps to 03:00 EDT, so 1:00 is 06:00 UTC and 3:30 is 07:30 UTC
+	window := newTestWindow(t, "0 1 * * *", 2*time.Hour, "America/New_York")
+	if !window.isActive(time.Date(2021, 3, 14, 7, 30, 0, 0, time.UTC)) {
+		t.Error("window is not active 1.5 hours after it opened on the day DST starts")
+	}
+	if window.isActive(time.Date(2021, 3, 14, 8, 30, 0, 0, time.UTC)) {
+		t.Error("window is active 2.5 hours after it opened on the day DST starts")
+	}
+
+	// 2:30 does not exist on the day DST starts, so no window opens that day
+	skipped := newTestWindow(t, "30 2 * * *", time.Hour, "America/New_York")
+	if skipped.isActive(time.Date(2021, 3, 14, 7, 45, 0, 0, time.UTC)) {
+		t.Error("window opened at a time which does not exist")
+	}
+	if !skipped.isActive(time.Date(2021, 3, 15, 6, 45, 0, 0, time.UTC)) {
+		t.Error("window did not open the day after DST started")
+	}
+
+	// 2021-11-07 02:00 EDT falls back to 01:00 EST, so 1:00 happens at 05:00 and again at 06:00 UTC
+	if !window.isActive(time.Date(2021, 11, 7, 7, 30, 0, 0, time.UTC)) {
+		t.Error("window did not open again at the repeated 1:00 on the day DST ends")
+	}
+	if window.isActive(time.Date(2021, 11, 7, 8, 30, 0, 0, time.UTC)) {
+		t.Error("window is active 2.5 hours after it last opened on the day DST ends")
+	}
+}
+
+func TestWindowIsActiveAsMinuteByMinuteSearch(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("time zone database is not available")
+	}
+
+	windows := []ScheduleWindow{
+		{Cron: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 4 * time.Hour}, TimeZone: "Asia/Tokyo"},
+		{Cron: "*/20 1-3 * * *", Duration: metav1.Duration{Duration: 7 * time.Minute}, TimeZone: "America/New_York"},
+		{Cron: "30 2 1,15 * 0", Duration: metav1.Duration{Duration: 26 * time.Hour}, TimeZone: "America/New_York"},
+		{Cron: "45 23 * * 4", Duration: metav1.Duration{Duration: 3 * 24 * time.Hour}},
+	}
+	for _, w := range windows {
+		window := newTestWindow(t, w.Cron, w.Duration.Duration, w.TimeZone)
+		// around the start and the end of DST
+		for _, from := range []time.Time{
+			time.Date(2021, 3, 12, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 11, 5, 0, 0, 0, 0, time.UTC),
+		} {
+			for at := from; at.Before(from.AddDate(0, 0, 4)); at = at.Add(7 * time.Minute) {
+				want := false
+				for start := at.Truncate(time.Minute); at.Sub(start) < window.duration; start = start.Add(-time.Minute) {
+					if window.cron.matches(start.In(window.location)) {
+						want = true
+						break
+					}
+				}
+				if got := window.isActive(at); got != want {
+					t.Fatalf("%q isActive(%s) = %t, want %t", w.Cron, at, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestScheduleIsAllowed(t *testing.T) {
+	schedule, err := Schedule{
+		Windows: []ScheduleWindow{{Cron: "0 9 * * *", Duration: metav1.Duration{Duration: 8 * time.Hour}}},
+		// no migrations around the daily batch at noon
+		Blackouts: []ScheduleWindow{{Cron: "30 11 * * *", Duration: metav1.Duration{Duration: time.Hour}}},
+	}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		hour   int
+		minute int
+		want   bool
+	}{
+		{8, 59, false},
+		{9, 0, true},
+		{11, 29, true},
+		{11, 30, false},
+		{12, 29, false},
+		{12, 30, true},
+		{17, 0, false},
+	}
+	for _, c := range cases {
+		at := time.Date(2021, 2, 1, c.hour, c.minute, 0, 0, time.UTC)
+		if got := schedule.IsAllowed(at); got != c.want {
+			t.Errorf("IsAllowed(%s) = %t, want %t", at.Format("15:04"), got, c.want)
+		}
+	}
+
+	empty, err := Schedule{}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty.IsAllowed(time.Now()) {
+		t.Error("a schedule without windows does not allow migrations")
+	}
+}
+
+func TestScheduleWindowValidate(t *testing.T) {
+	invalid := []ScheduleWindow{
+		{Cron: "0 22 * *", Duration: metav1.Duration{Duration: time.Hour}},
+		{Cron: "0 22 * * *"},
+		{Cron: "0 22 * * *", Duration: metav1.Duration{Duration: maxWindowDuration + time.Minute}},
+		{Cron: "0 22 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus_Mons"},
+	}
+	for _, window := range invalid {
+		if err := window.Validate(); err == nil {
+			t.Errorf("%+v is valid, want an error", window)
+		}
+	}
+}
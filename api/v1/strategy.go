@@ -16,35 +16,347 @@ limitations under the License.
 package v1
 
 import (
+	"context"
 	"errors"
-	appsv1 "k8s.io/api/apps/v1"
+	"fmt"
 	corev1 "k8s.io/api/core/v1"
-	"reflect"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	k8s "trsnium.com/baton/controllers/kubernetes"
 )
 
+// TaintSelector matches the taints with the key. Value and Effect narrow it down when they are set.
+type TaintSelector struct {
+	Key string `json:"key"`
+	// +optional
+	Value string `json:"value,omitempty"`
+	// +optional
+	Effect corev1.TaintEffect `json:"effect,omitempty"`
+}
+
 type Strategy struct {
-	NodeMatchLabels map[string]string `json:"nodeMatchLabels"`
+	// +optional
+	NodeMatchLabels map[string]string `json:"nodeMatchLabels,omitempty"`
+	// NodeSelector selects the nodes of the strategy together with NodeMatchLabels
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// ExcludeTaints excludes the nodes having a taint matching any of them
+	// +optional
+	ExcludeTaints []TaintSelector `json:"excludeTaints,omitempty"`
+	// ExcludeNotReady excludes the nodes whose Ready condition is not True
+	// +optional
+	ExcludeNotReady bool `json:"excludeNotReady,omitempty"`
+	// KeepPods is an absolute number of pods or a percentage of the replicas, e.g. "30%".
+	// A percentage is resolved against the current replicas on each run and rounded up.
+	KeepPods intstr.IntOrString `json:"keepPods,omitempty"`
+	// Weight shares the replicas left by the strategies with KeepPods among the weighted strategies in proportion,
+	// e.g. 70, 20 and 10. It is resolved on each run, and can not be combined with KeepPods, MinPods or MaxPods.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+	// MinPods is the lower bound of the resolved KeepPods
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinPods *int32 `json:"minPods,omitempty"`
+	// MaxPods is the upper bound of the resolved KeepPods
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPods *int32 `json:"maxPods,omitempty"`
+	// resolvedKeepPods is KeepPods resolved by ResolveStrategies, which leaves KeepPods as it is written
+	// so that a strategy resolved to keep no pods is still told apart from one without keepPods
+	resolvedKeepPods int32
+	isResolved       bool
+	// TopologyKey is a node label, e.g. topology.kubernetes.io/zone, whose values are the domains
+	// the pods of the strategy are spread across. Nodes without the label belong to no domain.
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// MaxSkew is the largest difference allowed between the pods of two domains of TopologyKey. Defaults to 1.
 	// +kubebuilder:validation:Minimum=1
-	KeepPods int32 `json:"keepPods,omitempty"`
+	// +optional
+	MaxSkew *int32 `json:"maxSkew,omitempty"`
+}
+
+// GetMaxSkew returns MaxSkew, defaulting to 1
+func (r Strategy) GetMaxSkew() int32 {
+	if r.MaxSkew == nil {
+		return 1
+	}
+	return *r.MaxSkew
+}
+
+// GetPodsPerDomain counts the pods on the nodes of each domain of TopologyKey.
+// Every domain of the nodes is counted, even one without pods.
+func (r Strategy) GetPodsPerDomain(nodes []corev1.Node, pods []corev1.Pod) map[string]int32 {
+	podsPerDomain := map[string]int32{}
+	nodeDomains := map[string]string{}
+	for _, node := range nodes {
+		domain, ok := node.ObjectMeta.Labels[r.TopologyKey]
+		if !ok {
+			continue
+		}
+		nodeDomains[node.ObjectMeta.Name] = domain
+		podsPerDomain[domain] += 0
+	}
+	for _, pod := range pods {
+		if domain, ok := nodeDomains[pod.Spec.NodeName]; ok {
+			podsPerDomain[domain]++
+		}
+	}
+	return podsPerDomain
+}
+
+// ForDomains returns a copy of the strategy which only selects the nodes in the domains of TopologyKey
+func (r Strategy) ForDomains(domains []string) Strategy {
+	strategy := *r.DeepCopy()
+	strategy.NodeSelector = r.labelSelector()
+	strategy.NodeMatchLabels = nil
+	strategy.NodeSelector.MatchExpressions = append(strategy.NodeSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      r.TopologyKey,
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   domains,
+	})
+	return strategy
 }
 
-func (r Strategy) GetMatchNodes(c client.Client) ([]corev1.Node, error) {
-	nodes, err := k8s.ListNodeMatchLabels(c, r.NodeMatchLabels)
-	return nodes, err
+// GetKeepPods returns KeepPods of a strategy resolved by ResolveStrategies
+func (r Strategy) GetKeepPods() int32 {
+	if r.isResolved {
+		return r.resolvedKeepPods
+	}
+	return int32(r.KeepPods.IntValue())
 }
 
-func (r Strategy) GetPodsScheduledNodes(c client.Client, deployment appsv1.Deployment) ([]corev1.Pod, error) {
-	nodes, err := r.GetMatchNodes(c)
+// IsRest reports whether the strategy takes the pods the others do not keep, i.e. it has neither keepPods
+// nor a weight. A strategy whose keepPods or weight resolves to 0 keeps no pods instead.
+func (r Strategy) IsRest() bool {
+	return r.Weight == nil && r.KeepPods == (intstr.IntOrString{})
+}
+
+// ResolveKeepPods resolves KeepPods against the replicas and clamps it between MinPods and MaxPods.
+// A strategy without keepPods resolves to 0, since it takes the pods the others do not keep.
+func (r Strategy) ResolveKeepPods(replicas int32) (int32, error) {
+	if r.MinPods != nil && r.MaxPods != nil && *r.MinPods > *r.MaxPods {
+		return 0, fmt.Errorf("minPods (%d) must not be greater than maxPods (%d)", *r.MinPods, *r.MaxPods)
+	}
+	if r.IsRest() {
+		return 0, nil
+	}
+
+	// a negative percentage is checked against 100, since it may resolve to 0 for few replicas
+	value, err := intstr.GetValueFromIntOrPercent(&r.KeepPods, 100, true)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("keepPods (%s) must not be negative", r.KeepPods.String())
+	}
+
+	keepPods, err := intstr.GetValueFromIntOrPercent(&r.KeepPods, int(replicas), true)
+	if err != nil {
+		return 0, err
+	}
+	if r.MinPods != nil && int32(keepPods) < *r.MinPods {
+		keepPods = int(*r.MinPods)
+	}
+	if r.MaxPods != nil && int32(keepPods) > *r.MaxPods {
+		keepPods = int(*r.MaxPods)
+	}
+	if keepPods < 0 {
+		keepPods = 0
+	}
+	return int32(keepPods), nil
+}
+
+// ResolveStrategies returns copies of the strategies whose KeepPods is an absolute number for the replicas.
+// The weighted strategies share the replicas left by the others by the largest remainder method.
+func ResolveStrategies(strategies []Strategy, replicas int32) ([]Strategy, error) {
+	resolvedStrategies := []Strategy{}
+	var fixedPods int32
+	for _, strategy := range strategies {
+		resolvedStrategy := *strategy.DeepCopy()
+		if strategy.Weight == nil {
+			keepPods, err := strategy.ResolveKeepPods(replicas)
+			if err != nil {
+				return nil, err
+			}
+			resolvedStrategy.resolvedKeepPods = keepPods
+			resolvedStrategy.isResolved = true
+			fixedPods += keepPods
+		}
+		resolvedStrategies = append(resolvedStrategies, resolvedStrategy)
+	}
+
+	weightedPods := replicas - fixedPods
+	if weightedPods < 0 {
+		weightedPods = 0
+	}
+	for i, keepPods := range distributeByWeight(strategies, weightedPods) {
+		if strategies[i].Weight != nil {
+			resolvedStrategies[i].resolvedKeepPods = keepPods
+			resolvedStrategies[i].isResolved = true
+		}
+	}
+	return resolvedStrategies, nil
+}
+
+// distributeByWeight splits the pods among the weighted strategies in proportion to their weights.
+// Each gets the integer part of its share, and the pods left go one each to the largest fractional parts,
+// the earlier strategy first on a tie, so the result is the same on every run.
+func distributeByWeight(strategies []Strategy, pods int32) []int32 {
+	distribution := make([]int32, len(strategies))
+	var totalWeight int64
+	for _, strategy := range strategies {
+		if strategy.Weight != nil {
+			totalWeight += int64(*strategy.Weight)
+		}
+	}
+	if totalWeight == 0 {
+		return distribution
+	}
+
+	remainders := make([]int64, len(strategies))
+	weighted := []int{}
+	leftPods := pods
+	for i, strategy := range strategies {
+		if strategy.Weight == nil {
+			continue
+		}
+		share := int64(pods) * int64(*strategy.Weight)
+		distribution[i] = int32(share / totalWeight)
+		remainders[i] = share % totalWeight
+		leftPods -= distribution[i]
+		weighted = append(weighted, i)
+	}
+
+	sort.SliceStable(weighted, func(a, b int) bool {
+		return remainders[weighted[a]] > remainders[weighted[b]]
+	})
+	for _, i := range weighted[:leftPods] {
+		distribution[i]++
+	}
+	return distribution
+}
+
+// HasWeightedStrategies reports whether any of the strategies has a weight
+func HasWeightedStrategies(strategies []Strategy) bool {
+	for _, strategy := range strategies {
+		if strategy.Weight != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector merges NodeMatchLabels and NodeSelector into a single node selector
+func (r Strategy) Selector() (labels.Selector, error) {
+	return metav1.LabelSelectorAsSelector(r.labelSelector())
+}
+
+// NodeSelectorTerm expresses the node selector of the strategy as a term of a node affinity.
+// The excluded nodes are kept out by name, since a node affinity can not select taints nor readiness.
+func (r Strategy) NodeSelectorTerm(excludedNodes []corev1.Node) corev1.NodeSelectorTerm {
+	selector := r.labelSelector()
+	term := corev1.NodeSelectorTerm{}
+
+	keys := []string{}
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{selector.MatchLabels[key]},
+		})
+	}
+	// the operators of a label selector have the same names in a node selector
+	for _, expression := range selector.MatchExpressions {
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      expression.Key,
+			Operator: corev1.NodeSelectorOperator(expression.Operator),
+			Values:   expression.Values,
+		})
+	}
+	// a node field selector takes a single value per requirement
+	for _, node := range excludedNodes {
+		term.MatchFields = append(term.MatchFields, corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   []string{node.ObjectMeta.Name},
+		})
+	}
+	return term
+}
+
+func (r Strategy) labelSelector() *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{}}
+	if r.NodeSelector != nil {
+		selector = r.NodeSelector.DeepCopy()
+		if selector.MatchLabels == nil {
+			selector.MatchLabels = map[string]string{}
+		}
+	}
+	for key, value := range r.NodeMatchLabels {
+		selector.MatchLabels[key] = value
+	}
+	return selector
+}
+
+func (r Strategy) GetMatchNodes(ctx context.Context, c client.Client) ([]corev1.Node, error) {
+	selector, err := r.Selector()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := k8s.ListNodeMatchSelector(ctx, c, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return k8s.FilterNodes(nodes, func(node corev1.Node) bool {
+		return !r.isExcluded(node)
+	}), nil
+}
+
+// GetExcludedNodes returns the nodes selected by the strategy but excluded by ExcludeNotReady or ExcludeTaints
+func (r Strategy) GetExcludedNodes(ctx context.Context, c client.Client) ([]corev1.Node, error) {
+	selector, err := r.Selector()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := k8s.ListNodeMatchSelector(ctx, c, selector)
 	if err != nil {
 		return nil, err
 	}
 
-	podLabels := deployment.Spec.Template.ObjectMeta.Labels
+	return k8s.FilterNodes(nodes, r.isExcluded), nil
+}
+
+func (r Strategy) isExcluded(node corev1.Node) bool {
+	if r.ExcludeNotReady && !k8s.IsNodeReady(node) {
+		return true
+	}
+	for _, excludedTaint := range r.ExcludeTaints {
+		if k8s.HasTaint(node, corev1.Taint{Key: excludedTaint.Key, Value: excludedTaint.Value, Effect: excludedTaint.Effect}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Strategy) GetPodsScheduledNodes(ctx context.Context, c client.Client, workload k8s.Workload) ([]corev1.Pod, error) {
+	nodes, err := r.GetMatchNodes(ctx, c)
+	if err != nil {
+		return nil, err
+	}
 
 	var pods []corev1.Pod
-	pods, err = k8s.ListPodMatchLabels(c, deployment.ObjectMeta.Namespace, podLabels)
+	pods, err = k8s.ListPodMatchSelector(ctx, c, workload.Namespace, workload.Selector)
 	if err != nil {
 		return nil, err
 	}
@@ -60,28 +372,28 @@ func (r Strategy) GetPodsScheduledNodes(c client.Client, deployment appsv1.Deplo
 }
 
 func (r *Strategy) IsSuplus(pods []corev1.Pod) bool {
-	if r.KeepPods == 0 {
+	if r.IsRest() {
 		return false
 	}
 
-	if int32(len(pods)) > r.KeepPods {
+	if int32(len(pods)) > r.GetKeepPods() {
 		return true
 	} else {
 		return false
 	}
 }
 
-func (r Strategy) IsSuplusWithPodsScheduledNodes(c client.Client, deployment appsv1.Deployment) (bool, error) {
-	if r.KeepPods == 0 {
+func (r Strategy) IsSuplusWithPodsScheduledNodes(ctx context.Context, c client.Client, workload k8s.Workload) (bool, error) {
+	if r.IsRest() {
 		return false, nil
 	}
 
-	pods, err := r.GetPodsScheduledNodes(c, deployment)
+	pods, err := r.GetPodsScheduledNodes(ctx, c, workload)
 	if err != nil {
 		return false, err
 	}
 
-	if int32(len(pods)) > r.KeepPods {
+	if int32(len(pods)) > r.GetKeepPods() {
 		return true, nil
 	} else {
 		return false, nil
@@ -89,28 +401,28 @@ func (r Strategy) IsSuplusWithPodsScheduledNodes(c client.Client, deployment app
 }
 
 func (r Strategy) IsLess(pods []corev1.Pod) bool {
-	if r.KeepPods == 0 {
+	if r.IsRest() {
 		return false
 	}
 
-	if int32(len(pods)) < r.KeepPods {
+	if int32(len(pods)) < r.GetKeepPods() {
 		return true
 	} else {
 		return false
 	}
 }
 
-func (r Strategy) IsLessWithPodsScheduledNodes(c client.Client, deployment appsv1.Deployment) (bool, error) {
-	if r.KeepPods == 0 {
+func (r Strategy) IsLessWithPodsScheduledNodes(ctx context.Context, c client.Client, workload k8s.Workload) (bool, error) {
+	if r.IsRest() {
 		return false, nil
 	}
 
-	pods, err := r.GetPodsScheduledNodes(c, deployment)
+	pods, err := r.GetPodsScheduledNodes(ctx, c, workload)
 	if err != nil {
 		return false, err
 	}
 
-	if int32(len(pods)) < r.KeepPods {
+	if int32(len(pods)) < r.GetKeepPods() {
 		return true, nil
 	} else {
 		return false, nil
@@ -128,12 +440,13 @@ func FilterStrategies(strategies []Strategy, f func(Strategy) bool) []Strategy {
 }
 
 func GetStrategiesMatchNodes(
+	ctx context.Context,
 	c client.Client,
 	strategies []Strategy,
 ) ([]corev1.Node, error) {
 	nodes := []corev1.Node{}
 	for _, strategy := range strategies {
-		snodes, err := strategy.GetMatchNodes(c)
+		snodes, err := strategy.GetMatchNodes(ctx, c)
 		if err != nil {
 			return []corev1.Node{}, err
 		}
@@ -146,13 +459,14 @@ func GetStrategiesMatchNodes(
 }
 
 func GetStrategiesPodsScheduledNodes(
+	ctx context.Context,
 	c client.Client,
-	deployment appsv1.Deployment,
+	workload k8s.Workload,
 	strategies []Strategy,
 ) ([]corev1.Pod, error) {
 	pods := []corev1.Pod{}
 	for _, strategy := range strategies {
-		spods, err := strategy.GetPodsScheduledNodes(c, deployment)
+		spods, err := strategy.GetPodsScheduledNodes(ctx, c, workload)
 		if err != nil {
 			return []corev1.Pod{}, err
 		}
@@ -164,31 +478,22 @@ func GetStrategiesPodsScheduledNodes(
 	return pods, nil
 }
 
-func (r Strategy) Equals(s Strategy) bool {
-	isEqualMatchLabels := reflect.DeepEqual(r.NodeMatchLabels, s.NodeMatchLabels)
-	return r.KeepPods != s.KeepPods && isEqualMatchLabels
-}
-
 func GetTotalKeepPods(strategies []Strategy) int {
 	total_keep_pods := 0
 	for _, strategy := range strategies {
-		total_keep_pods += int(strategy.KeepPods)
+		total_keep_pods += int(strategy.GetKeepPods())
 	}
 	return total_keep_pods
 }
 
-func ValidateStrategies(c client.Client, deployment appsv1.Deployment, strategies []Strategy) error {
-	pods, err := k8s.ListPodMatchLabels(
-		c,
-		deployment.ObjectMeta.Namespace,
-		deployment.Spec.Template.ObjectMeta.Labels,
-	)
+func ValidateStrategies(ctx context.Context, c client.Client, workload k8s.Workload, strategies []Strategy) error {
+	pods, err := k8s.ListPodMatchSelector(ctx, c, workload.Namespace, workload.Selector)
 	if err != nil {
 		return err
 	}
 
 	var nodes []corev1.Node
-	nodes, err = GetStrategiesMatchNodes(c, strategies)
+	nodes, err = GetStrategiesMatchNodes(ctx, c, strategies)
 	if err != nil {
 		return err
 	}
@@ -203,7 +508,7 @@ func ValidateStrategies(c client.Client, deployment appsv1.Deployment, strategie
 	})
 
 	if len(runningPodsScheduledOnStrategiesNode) != len(pods) {
-		return errors.New("Deployment pods should always be on nodes of all strategies")
+		return errors.New("Workload pods should always be on nodes of all strategies")
 	}
 
 	totalKeepPods := GetTotalKeepPods(strategies)
@@ -213,9 +518,23 @@ func ValidateStrategies(c client.Client, deployment appsv1.Deployment, strategie
 		}
 		return false
 	})
-	if !(len(runningPods) > totalKeepPods) {
+	// the weighted strategies share all of the replicas, so no pods are left over for the others
+	if HasWeightedStrategies(strategies) {
+		if len(runningPods) < totalKeepPods {
+			return errors.New("The number of running pods must not be less than the sum of all strategy KeepPods")
+		}
+	} else if !(len(runningPods) > totalKeepPods) {
 		return errors.New("The number of running pods must be greater than the sum of all strategy KeepPods")
 	}
 
 	return nil
 }
+
+// String identifies the strategy by its node selector, e.g. in logs and metrics
+func (r Strategy) String() string {
+	selector, err := r.Selector()
+	if err != nil {
+		return labels.Set(r.NodeMatchLabels).String()
+	}
+	return selector.String()
+}
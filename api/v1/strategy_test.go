@@ -0,0 +1,142 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func weight(w int32) *int32 {
+	return &w
+}
+
+func pods(n int32) *int32 {
+	return &n
+}
+
+func TestResolveStrategiesByWeight(t *testing.T) {
+	strategies := []Strategy{
+		{Weight: weight(70)},
+		{Weight: weight(20)},
+		{Weight: weight(10)},
+		{KeepPods: intstr.FromInt(2)},
+	}
+	resolved, err := ResolveStrategies(strategies, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int32{7, 2, 1, 2} {
+		if got := resolved[i].GetKeepPods(); got != want {
+			t.Errorf("strategies[%d] keeps %d pods, want %d", i, got, want)
+		}
+	}
+}
+
+func TestWeightedStrategyRoundedDownToZeroIsNotRest(t *testing.T) {
+	strategies := []Strategy{
+		{Weight: weight(95)},
+		{Weight: weight(5)},
+	}
+	resolved, err := ResolveStrategies(strategies, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolved[1].GetKeepPods(); got != 0 {
+		t.Fatalf("strategies[1] keeps %d pods, want 0", got)
+	}
+	if resolved[1].IsRest() {
+		t.Error("weighted strategy keeping 0 pods is treated as the rest")
+	}
+	if !resolved[1].IsSuplus([]corev1.Pod{{}}) {
+		t.Error("a pod on a weighted strategy keeping 0 pods is not suplus")
+	}
+
+	rest := Strategy{}
+	if !rest.IsRest() || rest.IsSuplus([]corev1.Pod{{}}) {
+		t.Error("strategy without keepPods does not take the rest")
+	}
+}
+
+func TestResolveKeepPodsBounds(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy Strategy
+		replicas int32
+		want     int32
+	}{
+		{"percentage rounded up", Strategy{KeepPods: intstr.FromString("30%")}, 5, 2},
+		{"bounded by maxPods", Strategy{KeepPods: intstr.FromString("50%"), MaxPods: pods(3)}, 10, 3},
+		{"raised to minPods", Strategy{KeepPods: intstr.FromString("10%"), MinPods: pods(2)}, 5, 2},
+		{"minPods applies when keepPods resolves to 0", Strategy{KeepPods: intstr.FromString("0%"), MinPods: pods(2)}, 10, 2},
+	}
+	for _, c := range cases {
+		got, err := c.strategy.ResolveKeepPods(c.replicas)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: ResolveKeepPods(%d) = %d, want %d", c.name, c.replicas, got, c.want)
+		}
+	}
+}
+
+func TestStrategyResolvedToZeroIsNotRest(t *testing.T) {
+	strategies := []Strategy{
+		{NodeMatchLabels: map[string]string{"pool": "a"}, KeepPods: intstr.FromString("0%")},
+		{NodeMatchLabels: map[string]string{"pool": "b"}, KeepPods: intstr.FromInt(5), MaxPods: pods(0)},
+		{NodeMatchLabels: map[string]string{"pool": "c"}},
+	}
+	resolved, err := ResolveStrategies(strategies, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range strategies[:2] {
+		if resolved[i].IsRest() || resolved[i].GetKeepPods() != 0 {
+			t.Errorf("strategies[%d] is rest %t keeping %d pods, want a strategy keeping 0 pods", i, resolved[i].IsRest(), resolved[i].GetKeepPods())
+		}
+	}
+	if !resolved[2].IsRest() {
+		t.Error("strategy without keepPods does not take the rest")
+	}
+	// ResolveStrategies leaves the spec as it is written
+	if strategies[0].KeepPods != intstr.FromString("0%") {
+		t.Errorf("keepPods of the spec is changed to %s", strategies[0].KeepPods.String())
+	}
+}
+
+func TestNodeSelectorTermExcludesNodes(t *testing.T) {
+	strategy := Strategy{NodeMatchLabels: map[string]string{"pool": "a"}, ExcludeNotReady: true}
+	excludedNodes := []corev1.Node{{}, {}}
+	excludedNodes[0].ObjectMeta.Name = "node-1"
+	excludedNodes[1].ObjectMeta.Name = "node-2"
+
+	term := strategy.NodeSelectorTerm(excludedNodes)
+	if len(term.MatchExpressions) != 1 || term.MatchExpressions[0].Key != "pool" {
+		t.Errorf("MatchExpressions are %v, want pool In [a]", term.MatchExpressions)
+	}
+	if len(term.MatchFields) != len(excludedNodes) {
+		t.Fatalf("MatchFields are %v, want one per excluded node", term.MatchFields)
+	}
+	for i, field := range term.MatchFields {
+		if field.Key != "metadata.name" || field.Operator != corev1.NodeSelectorOpNotIn || len(field.Values) != 1 || field.Values[0] != excludedNodes[i].ObjectMeta.Name {
+			t.Errorf("MatchFields[%d] is %v, want metadata.name NotIn [%s]", i, field, excludedNodes[i].ObjectMeta.Name)
+		}
+	}
+}
@@ -17,26 +17,43 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	batonv1 "trsnium.com/baton/api/v1"
 )
 
+// batonFinalizer keeps a Baton until the nodes it cordoned are released
+const batonFinalizer = "baton.baton/finalizer"
+
 // BatonReconciler reconciles a Baton object
 type BatonReconciler struct {
 	client.Client
 	Log                          logr.Logger
 	Scheme                       *runtime.Scheme
+	Recorder                     record.EventRecorder
 	BatonStrategiesRunnerManager *BatonStrategiesRunnerManager
 }
 
 // +kubebuilder:rbac:groups=baton.baton,resources=batons,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=baton.baton,resources=batons/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;replicasets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;patch
+// +kubebuilder:rbac:groups=*,resources=*/scale,verbs=get;update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 func (r *BatonReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 
@@ -46,24 +63,119 @@ func (r *BatonReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	for _, baton := range batons.Items {
+		if !baton.ObjectMeta.DeletionTimestamp.IsZero() {
+			if err := r.finalize(ctx, baton); err != nil {
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+
+		if !contains(baton.ObjectMeta.Finalizers, batonFinalizer) {
+			baton.ObjectMeta.Finalizers = append(baton.ObjectMeta.Finalizers, batonFinalizer)
+			if err := r.Client.Update(ctx, &baton); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
 		if !r.BatonStrategiesRunnerManager.IsManaged(baton) {
 			r.BatonStrategiesRunnerManager.Add(baton)
 			continue
 		}
 
+		// the runner keeps a migration in progress and applies the spec before its next run
 		if r.BatonStrategiesRunnerManager.IsUpdated(baton) {
-			r.BatonStrategiesRunnerManager.Delete(baton)
-			r.BatonStrategiesRunnerManager.Add(baton)
+			r.BatonStrategiesRunnerManager.Update(baton)
 		}
+		r.BatonStrategiesRunnerManager.SetSuspended(baton)
 	}
 
+	r.recordDuplicateWorkloads(batons)
 	r.BatonStrategiesRunnerManager.DeleteNotExists(batons)
+	if err := r.BatonStrategiesRunnerManager.ReleaseOrphanedCordons(ctx, batons); err != nil {
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+// recordDuplicateWorkloads warns with an Event on each Baton targeting the same workload as another,
+// since their runners would migrate the pods against each other. The webhook can not warn the user
+// who applies the Baton, as admission warnings are not available in this version of controller-runtime.
+func (r *BatonReconciler) recordDuplicateWorkloads(batons *batonv1.BatonList) {
+	batonsPerWorkload := map[string][]int{}
+	workloads := []string{}
+	for i := range batons.Items {
+		if !batons.Items[i].ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		workloadRef := batons.Items[i].GetWorkloadRef()
+		workload := fmt.Sprintf("%s{Namespace: %s, Name: %s}", workloadRef.GroupVersionKind().GroupKind(), workloadRef.Namespace, workloadRef.Name)
+		if _, ok := batonsPerWorkload[workload]; !ok {
+			workloads = append(workloads, workload)
+		}
+		batonsPerWorkload[workload] = append(batonsPerWorkload[workload], i)
+	}
+
+	for _, workload := range workloads {
+		indices := batonsPerWorkload[workload]
+		if len(indices) < 2 {
+			continue
+		}
+		for _, i := range indices {
+			others := []string{}
+			for _, j := range indices {
+				if i != j {
+					others = append(others, fmt.Sprintf("%s/%s", batons.Items[j].ObjectMeta.Namespace, batons.Items[j].ObjectMeta.Name))
+				}
+			}
+			r.Recorder.Eventf(&batons.Items[i], corev1.EventTypeWarning, "DuplicateWorkload", "%s is also targeted by Batons %v", workload, others)
+		}
+	}
+}
+
+// finalize stops the runner of the deleted Baton and uncordons the nodes it left cordoned
+func (r *BatonReconciler) finalize(ctx context.Context, baton batonv1.Baton) error {
+	if !contains(baton.ObjectMeta.Finalizers, batonFinalizer) {
+		return nil
+	}
+
+	if r.BatonStrategiesRunnerManager.IsManaged(baton) {
+		r.BatonStrategiesRunnerManager.Delete(baton)
+	}
+
+	if err := r.BatonStrategiesRunnerManager.ReleaseCordons(ctx, baton); err != nil {
+		return err
+	}
+
+	finalizers := []string{}
+	for _, finalizer := range baton.ObjectMeta.Finalizers {
+		if finalizer != batonFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	baton.ObjectMeta.Finalizers = finalizers
+	return r.Client.Update(ctx, &baton)
+}
+
+// specOrPauseChangedPredicate passes updates which change the generation or the pause annotation,
+// since annotations do not bump the generation
+type specOrPauseChangedPredicate struct {
+	predicate.GenerationChangedPredicate
+}
+
+func (p specOrPauseChangedPredicate) Update(e event.UpdateEvent) bool {
+	if p.GenerationChangedPredicate.Update(e) {
+		return true
+	}
+	if e.MetaOld == nil || e.MetaNew == nil {
+		return false
+	}
+	return e.MetaOld.GetAnnotations()[batonv1.PauseAnnotation] != e.MetaNew.GetAnnotations()[batonv1.PauseAnnotation]
+}
+
 func (r *BatonReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&batonv1.Baton{}).
+		// status is written by the runners, so only spec changes and the pause annotation should trigger a reconcile
+		For(&batonv1.Baton{}, builder.WithPredicates(specOrPauseChangedPredicate{})).
 		Owns(&appsv1.Deployment{}).
 		Complete(r)
 }
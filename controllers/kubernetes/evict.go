@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrEvictionBlocked is returned when a PodDisruptionBudget keeps rejecting an eviction
+var ErrEvictionBlocked = errors.New("eviction is blocked by PodDisruptionBudget")
+
+// evictionBackoff is applied while the API server answers 429 TooManyRequests,
+// which is how a PodDisruptionBudget refuses a disruption
+var evictionBackoff = wait.Backoff{
+	Duration: 5 * time.Second,
+	Factor:   2,
+	Steps:    5,
+}
+
+// EvictPod evicts the pod through the eviction subresource so that PodDisruptionBudgets are respected.
+// The policy/v1 Eviction is not served by the client in use, so policy/v1beta1 is posted instead.
+func EvictPod(ctx context.Context, cs kubernetes.Interface, pod corev1.Pod) error {
+	eviction := &policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pod.ObjectMeta.Namespace,
+			Name:      pod.ObjectMeta.Name,
+		},
+	}
+
+	// the backoff is stepped by hand, since wait.ExponentialBackoff does not stop when ctx is cancelled
+	backoff := evictionBackoff
+	for {
+		err := cs.CoreV1().Pods(pod.ObjectMeta.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+			return nil
+		case !apierrors.IsTooManyRequests(err):
+			return err
+		}
+
+		if backoff.Steps <= 1 {
+			return ErrEvictionBlocked
+		}
+		select {
+		case <-time.After(backoff.Step()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
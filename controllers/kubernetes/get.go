@@ -2,13 +2,11 @@ package kubernetes
 
 import (
 	"context"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func GetPod(c client.Client, namespace string, name string) (corev1.Pod, error) {
-	ctx := context.Background()
+func GetPod(ctx context.Context, c client.Client, namespace string, name string) (corev1.Pod, error) {
 	pod := corev1.Pod{}
 	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &pod)
 	if err != nil {
@@ -17,8 +15,7 @@ func GetPod(c client.Client, namespace string, name string) (corev1.Pod, error)
 	return pod, nil
 }
 
-func GetNode(c client.Client, name string) (corev1.Node, error) {
-	ctx := context.Background()
+func GetNode(ctx context.Context, c client.Client, name string) (corev1.Node, error) {
 	node := corev1.Node{}
 	err := c.Get(ctx, client.ObjectKey{Name: name}, &node)
 	if err != nil {
@@ -26,13 +23,3 @@ func GetNode(c client.Client, name string) (corev1.Node, error) {
 	}
 	return node, nil
 }
-
-func GetDeployment(c client.Client, namespace string, name string) (appsv1.Deployment, error) {
-	ctx := context.Background()
-	deployment := appsv1.Deployment{}
-	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &deployment)
-	if err != nil {
-		return appsv1.Deployment{}, err
-	}
-	return deployment, nil
-}
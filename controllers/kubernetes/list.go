@@ -3,15 +3,15 @@ package kubernetes
 import (
 	"context"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func ListPodMatchLabels(c client.Client, namespace string, labels map[string]string) ([]corev1.Pod, error) {
-	ctx := context.Background()
+func ListPodMatchSelector(ctx context.Context, c client.Client, namespace string, selector labels.Selector) ([]corev1.Pod, error) {
 	podList := corev1.PodList{}
 	err := c.List(ctx, &podList,
 		client.InNamespace(namespace),
-		client.MatchingLabels(labels),
+		client.MatchingLabelsSelector{Selector: selector},
 	)
 
 	if err != nil {
@@ -20,11 +20,10 @@ func ListPodMatchLabels(c client.Client, namespace string, labels map[string]str
 	return podList.Items, nil
 }
 
-func ListNodeMatchLabels(c client.Client, labels map[string]string) ([]corev1.Node, error) {
-	ctx := context.Background()
+func ListNodeMatchSelector(ctx context.Context, c client.Client, selector labels.Selector) ([]corev1.Node, error) {
 	nodeList := corev1.NodeList{}
 	err := c.List(ctx, &nodeList,
-		client.MatchingLabels(labels),
+		client.MatchingLabelsSelector{Selector: selector},
 	)
 
 	if err != nil {
@@ -33,8 +32,7 @@ func ListNodeMatchLabels(c client.Client, labels map[string]string) ([]corev1.No
 	return nodeList.Items, nil
 }
 
-func ListNodes(c client.Client) ([]corev1.Node, error) {
-	ctx := context.Background()
+func ListNodes(ctx context.Context, c client.Client) ([]corev1.Node, error) {
 	nodes := corev1.NodeList{}
 	err := c.List(ctx, &nodes)
 	if err != nil {
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/client-go/scale"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SurgeReplicasAnnotation records the replicas a workload had before it was surged,
+// so that they can be restored even if the controller restarts in the middle of a migration
+const SurgeReplicasAnnotation = "baton.baton/surge-original-replicas"
+
+// SurgeWorkload adds surge replicas to the workload through its scale subresource and remembers the original count
+func SurgeWorkload(ctx context.Context, c client.Client, scales scale.ScalesGetter, workload *Workload, surge int32) error {
+	if _, isSurged := workload.Annotations[SurgeReplicasAnnotation]; isSurged {
+		return fmt.Errorf("%s is already surged", workload)
+	}
+
+	s, err := scales.Scales(workload.Namespace).Get(ctx, workload.GroupResource, workload.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// the annotation is written first, so that a crash before scaling only leads to a no-op restore
+	originalReplicas := strconv.Itoa(int(s.Spec.Replicas))
+	err = annotateWorkload(ctx, c, *workload, SurgeReplicasAnnotation, &originalReplicas)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{SurgeReplicasAnnotation: originalReplicas}
+	for key, value := range workload.Annotations {
+		annotations[key] = value
+	}
+	workload.Annotations = annotations
+
+	s.Spec.Replicas += surge
+	_, err = scales.Scales(workload.Namespace).Update(ctx, workload.GroupResource, s, metav1.UpdateOptions{})
+	return err
+}
+
+// RestoreSurgedWorkload scales the workload back to the replicas recorded by SurgeWorkload.
+// It does nothing when the workload is not surged.
+func RestoreSurgedWorkload(ctx context.Context, c client.Client, scales scale.ScalesGetter, workload Workload) error {
+	originalReplicas, isSurged := workload.Annotations[SurgeReplicasAnnotation]
+	if !isSurged {
+		return nil
+	}
+
+	replicas, err := strconv.ParseInt(originalReplicas, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	s, err := scales.Scales(workload.Namespace).Get(ctx, workload.GroupResource, workload.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	s.Spec.Replicas = int32(replicas)
+	_, err = scales.Scales(workload.Namespace).Update(ctx, workload.GroupResource, s, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	return annotateWorkload(ctx, c, workload, SurgeReplicasAnnotation, nil)
+}
+
+// annotateWorkload sets the annotation of the workload, or removes it when value is nil
+func annotateWorkload(ctx context.Context, c client.Client, workload Workload, key string, value *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{key: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(workload.GroupVersionKind)
+	object.SetNamespace(workload.Namespace)
+	object.SetName(workload.Name)
+	return c.Patch(ctx, object, client.RawPatch(types.MergePatchType, patch))
+}
@@ -1,30 +1,262 @@
 package kubernetes
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// RunCordonOrUncordon demonstrates the canonical way to cordon or uncordon a Node
-func RunCordonOrUncordon(c client.Client, node *corev1.Node, desired bool) error {
-	// TODO(justinsb): Ensure we have adequate e2e coverage of this function in library consumers
-	h := NewCordonHelper(node)
+// CordonedByAnnotation records the comma separated owners holding the cordon of a node,
+// so that only the last of them uncordons it
+const CordonedByAnnotation = "baton.baton/cordoned-by"
+
+// SteeringTaintKeyPrefix is the prefix of the taint keys Baton applies instead of cordoning nodes
+const SteeringTaintKeyPrefix = "steering.baton.baton/"
+
+// ToBeDeletedTaintKey is the taint the cluster autoscaler puts on a node before deleting it
+const ToBeDeletedTaintKey = "ToBeDeletedByClusterAutoscaler"
+
+// CordonNode cordons the node on behalf of owner and returns whether owner holds the cordon.
+// Several owners share the cordon of a node, which stays cordoned until the last of them uncordons it.
+// A node that was already cordoned by someone other than Baton is left untouched.
+func CordonNode(ctx context.Context, c client.Client, nodeName string, owner string) (bool, error) {
+	isOwned := false
+	// the node is read from the cache, which may take a moment to catch up after a conflict
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node, err := GetNode(ctx, c, nodeName)
+		if err != nil {
+			return err
+		}
+
+		owners := GetCordonOwners(node)
+		isOwned = node.Spec.Unschedulable && contains(owners, owner)
+		if isOwned || (node.Spec.Unschedulable && len(owners) == 0) {
+			return nil
+		}
+
+		if node.ObjectMeta.Annotations == nil {
+			node.ObjectMeta.Annotations = map[string]string{}
+		}
+		node.ObjectMeta.Annotations[CordonedByAnnotation] = strings.Join(append(owners, owner), ",")
+		node.Spec.Unschedulable = true
+		err = c.Update(ctx, &node)
+		isOwned = err == nil
+		return err
+	})
+	return isOwned, err
+}
+
+// UncordonNode releases the cordon owner holds on the node, and returns whether the node was uncordoned.
+// The node stays cordoned while other owners still hold it.
+func UncordonNode(ctx context.Context, c client.Client, nodeName string, owner string) (bool, error) {
+	isUncordoned := false
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		isUncordoned = false
+		node, err := GetNode(ctx, c, nodeName)
+		if err != nil {
+			return err
+		}
+
+		owners := GetCordonOwners(node)
+		if !contains(owners, owner) {
+			return nil
+		}
+
+		remainingOwners := []string{}
+		for _, o := range owners {
+			if o != owner {
+				remainingOwners = append(remainingOwners, o)
+			}
+		}
+		if len(remainingOwners) == 0 {
+			delete(node.ObjectMeta.Annotations, CordonedByAnnotation)
+			node.Spec.Unschedulable = false
+		} else {
+			node.ObjectMeta.Annotations[CordonedByAnnotation] = strings.Join(remainingOwners, ",")
+		}
+		err = c.Update(ctx, &node)
+		isUncordoned = err == nil && len(remainingOwners) == 0
+		return err
+	})
+	return isUncordoned, err
+}
 
-	if updateRequired := h.UpdateIfRequired(desired); !updateRequired {
-		// Already done
-		return nil
+// GetCordonOwners returns the owners holding the cordon of the node
+func GetCordonOwners(node corev1.Node) []string {
+	cordonedBy, isCordonedByBaton := node.ObjectMeta.Annotations[CordonedByAnnotation]
+	if !isCordonedByBaton || cordonedBy == "" {
+		return []string{}
 	}
+	return strings.Split(cordonedBy, ",")
+}
 
-	err, patchErr := h.PatchOrReplace(c)
-	if patchErr != nil {
-		return patchErr
+func contains(s []string, e string) bool {
+	for _, v := range s {
+		if e == v {
+			return true
+		}
 	}
+	return false
+}
 
-	if err != nil {
-		return err
+// GetNodeTerminationSignal returns why the node is about to be terminated, or "" if it is not.
+// A node is terminating when it is being deleted, is tainted by the cluster autoscaler before a scale down,
+// or carries any of the given taints, annotations or True conditions, e.g. those of a node termination handler.
+func GetNodeTerminationSignal(
+	node corev1.Node,
+	taintKeys []string,
+	annotations []string,
+	conditionTypes []corev1.NodeConditionType,
+) string {
+	if node.ObjectMeta.DeletionTimestamp != nil {
+		return "DeletionTimestamp"
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == ToBeDeletedTaintKey || contains(taintKeys, taint.Key) {
+			return fmt.Sprintf("taint %s", taint.Key)
+		}
 	}
+	for _, annotation := range annotations {
+		if _, ok := node.ObjectMeta.Annotations[annotation]; ok {
+			return fmt.Sprintf("annotation %s", annotation)
+		}
+	}
+	for _, condition := range node.Status.Conditions {
+		for _, conditionType := range conditionTypes {
+			if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+				return fmt.Sprintf("condition %s", condition.Type)
+			}
+		}
+	}
+	return ""
+}
 
-	return nil
+// IsNodeReady reports whether the Ready condition of the node is True
+func IsNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// IsPodAvailable reports whether the pod has been ready for at least minReadySeconds
+func IsPodAvailable(pod corev1.Pod, minReadySeconds int32, now time.Time) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodReady {
+			continue
+		}
+		if condition.Status != corev1.ConditionTrue {
+			return false
+		}
+		minReadyDuration := time.Duration(minReadySeconds) * time.Second
+		return minReadySeconds == 0 || !condition.LastTransitionTime.Add(minReadyDuration).After(now)
+	}
+	return false
+}
+
+// IsPodReady reports whether the Ready condition of the pod is true
+func IsPodReady(pod corev1.Pod) bool {
+	return IsPodAvailable(pod, 0, time.Now())
+}
+
+// podFailureReasons are the waiting reasons of a container which does not recover without a change
+var podFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// GetPodFailureReason returns why the pod cannot become ready, or an empty string while it still can
+func GetPodFailureReason(pod corev1.Pod) string {
+	if pod.Status.Phase == corev1.PodFailed {
+		return "Failed"
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled &&
+			condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return corev1.PodReasonUnschedulable
+		}
+	}
+	containerStatuses := []corev1.ContainerStatus{}
+	containerStatuses = append(containerStatuses, pod.Status.InitContainerStatuses...)
+	containerStatuses = append(containerStatuses, pod.Status.ContainerStatuses...)
+	for _, containerStatus := range containerStatuses {
+		if containerStatus.State.Waiting != nil && podFailureReasons[containerStatus.State.Waiting.Reason] {
+			return containerStatus.State.Waiting.Reason
+		}
+	}
+	return ""
+}
+
+// TaintNode adds the taint to the node unless the node already has it
+func TaintNode(ctx context.Context, c client.Client, nodeName string, taint corev1.Taint) error {
+	// the node is read from the cache, which may take a moment to catch up after a conflict
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node, err := GetNode(ctx, c, nodeName)
+		if err != nil {
+			return err
+		}
+		if HasTaint(node, corev1.Taint{Key: taint.Key, Effect: taint.Effect}) {
+			return nil
+		}
+
+		node.Spec.Taints = append(node.Spec.Taints, taint)
+		return c.Update(ctx, &node)
+	})
+}
+
+// UntaintNode removes the taints with the key from the node, and reports whether the node had any
+func UntaintNode(ctx context.Context, c client.Client, nodeName string, key string) (bool, error) {
+	isUntainted := false
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node, err := GetNode(ctx, c, nodeName)
+		if err != nil {
+			return err
+		}
+
+		taints := []corev1.Taint{}
+		for _, t := range node.Spec.Taints {
+			if t.Key != key {
+				taints = append(taints, t)
+			}
+		}
+		isUntainted = len(taints) != len(node.Spec.Taints)
+		if !isUntainted {
+			return nil
+		}
+
+		node.Spec.Taints = taints
+		return c.Update(ctx, &node)
+	})
+	return isUntainted, err
+}
+
+// HasTaint reports whether the node has a taint matching the given one.
+// An empty value or effect of the given taint matches any value or effect.
+func HasTaint(node corev1.Node, taint corev1.Taint) bool {
+	for _, t := range node.Spec.Taints {
+		if t.Key != taint.Key {
+			continue
+		}
+		if taint.Value != "" && t.Value != taint.Value {
+			continue
+		}
+		if taint.Effect != "" && t.Effect != taint.Effect {
+			continue
+		}
+		return true
+	}
+	return false
 }
 
 func FilterNodes(nodes []corev1.Node, f func(corev1.Node) bool) []corev1.Node {
@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type cordonStep struct {
+	uncordon bool
+	owner    string
+	// want is whether the owner holds the cordon after CordonNode, or the node is uncordoned after UncordonNode
+	want bool
+}
+
+func TestCordonAndUncordonNode(t *testing.T) {
+	cases := []struct {
+		name          string
+		unschedulable bool
+		steps         []cordonStep
+		wantCordoned  bool
+		wantOwners    []string
+	}{
+		{
+			name: "the last of two owners uncordons the node",
+			steps: []cordonStep{
+				{owner: "default/a", want: true},
+				{owner: "default/b", want: true},
+				{uncordon: true, owner: "default/a", want: false},
+				{uncordon: true, owner: "default/b", want: true},
+			},
+			wantCordoned: false,
+			wantOwners:   []string{},
+		},
+		{
+			name: "an owner cordoning twice is recorded once",
+			steps: []cordonStep{
+				{owner: "default/a", want: true},
+				{owner: "default/a", want: true},
+			},
+			wantCordoned: true,
+			wantOwners:   []string{"default/a"},
+		},
+		{
+			name:          "a node cordoned by an administrator is never taken over",
+			unschedulable: true,
+			steps: []cordonStep{
+				{owner: "default/a", want: false},
+				{uncordon: true, owner: "default/a", want: false},
+			},
+			wantCordoned: true,
+			wantOwners:   []string{},
+		},
+		{
+			name: "releasing a node an owner does not hold leaves it to the holder",
+			steps: []cordonStep{
+				{owner: "default/a", want: true},
+				{uncordon: true, owner: "default/b", want: false},
+			},
+			wantCordoned: true,
+			wantOwners:   []string{"default/a"},
+		},
+		{
+			name: "releasing an uncordoned node does nothing",
+			steps: []cordonStep{
+				{uncordon: true, owner: "default/a", want: false},
+			},
+			wantCordoned: false,
+			wantOwners:   []string{},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec:       corev1.NodeSpec{Unschedulable: c.unschedulable},
+		}
+		client := fake.NewFakeClientWithScheme(scheme, node)
+
+		for i, step := range c.steps {
+			var got bool
+			var err error
+			if step.uncordon {
+				got, err = UncordonNode(context.Background(), client, "node", step.owner)
+			} else {
+				got, err = CordonNode(context.Background(), client, "node", step.owner)
+			}
+			if err != nil {
+				t.Fatalf("%s: steps[%d] failed: %v", c.name, i, err)
+			}
+			if got != step.want {
+				t.Errorf("%s: steps[%d] by %s returned %t, want %t", c.name, i, step.owner, got, step.want)
+			}
+		}
+
+		result, err := GetNode(context.Background(), client, "node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Spec.Unschedulable != c.wantCordoned {
+			t.Errorf("%s: node is cordoned %t, want %t", c.name, result.Spec.Unschedulable, c.wantCordoned)
+		}
+		if owners := GetCordonOwners(result); !reflect.DeepEqual(owners, c.wantOwners) {
+			t.Errorf("%s: owners are %v, want %v", c.name, owners, c.wantOwners)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package kubernetes
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/scale"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	deploymentGroupKind  = schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"}
+	statefulSetGroupKind = schema.GroupKind{Group: appsv1.GroupName, Kind: "StatefulSet"}
+	replicaSetGroupKind  = schema.GroupKind{Group: appsv1.GroupName, Kind: "ReplicaSet"}
+)
+
+// podRevisionLabels are the labels workload controllers put on pods to tell their revisions apart
+var podRevisionLabels = []string{
+	"pod-template-hash",
+	"controller-revision-hash",
+	"rollouts-pod-template-hash",
+}
+
+// Workload is a scalable object whose pods are rebalanced across strategies
+type Workload struct {
+	Object           runtime.Object
+	GroupVersionKind schema.GroupVersionKind
+	GroupResource    schema.GroupResource
+	Namespace        string
+	Name             string
+	Annotations      map[string]string
+	Replicas         int32
+	Selector         labels.Selector
+	MinReadySeconds  int32
+}
+
+// IsStatefulSet reports whether pods of the workload keep their names across restarts
+func (r Workload) IsStatefulSet() bool {
+	return r.GroupVersionKind.GroupKind() == statefulSetGroupKind
+}
+
+func (r Workload) String() string {
+	return r.GroupVersionKind.Kind + "{Namespace: " + r.Namespace + ", Name: " + r.Name + "}"
+}
+
+// GetWorkload resolves the replicas and the pod selector of the workload.
+// Deployments, StatefulSets and ReplicaSets are read as they are,
+// any other kind is resolved through its scale subresource.
+func GetWorkload(
+	ctx context.Context,
+	c client.Client,
+	mapper meta.RESTMapper,
+	scales scale.ScalesGetter,
+	gvk schema.GroupVersionKind,
+	namespace string,
+	name string,
+) (Workload, error) {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return Workload{}, err
+	}
+
+	workload := Workload{
+		GroupVersionKind: gvk,
+		GroupResource:    mapping.Resource.GroupResource(),
+		Namespace:        namespace,
+		Name:             name,
+		Replicas:         1,
+	}
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+
+	var selector *metav1.LabelSelector
+	switch gvk.GroupKind() {
+	case deploymentGroupKind:
+		deployment := appsv1.Deployment{}
+		if err := c.Get(ctx, key, &deployment); err != nil {
+			return Workload{}, err
+		}
+		workload.Object = &deployment
+		workload.Annotations = deployment.ObjectMeta.Annotations
+		workload.MinReadySeconds = deployment.Spec.MinReadySeconds
+		if deployment.Spec.Replicas != nil {
+			workload.Replicas = *deployment.Spec.Replicas
+		}
+		selector = deployment.Spec.Selector
+	case statefulSetGroupKind:
+		statefulSet := appsv1.StatefulSet{}
+		if err := c.Get(ctx, key, &statefulSet); err != nil {
+			return Workload{}, err
+		}
+		workload.Object = &statefulSet
+		workload.Annotations = statefulSet.ObjectMeta.Annotations
+		if statefulSet.Spec.Replicas != nil {
+			workload.Replicas = *statefulSet.Spec.Replicas
+		}
+		selector = statefulSet.Spec.Selector
+	case replicaSetGroupKind:
+		replicaSet := appsv1.ReplicaSet{}
+		if err := c.Get(ctx, key, &replicaSet); err != nil {
+			return Workload{}, err
+		}
+		workload.Object = &replicaSet
+		workload.Annotations = replicaSet.ObjectMeta.Annotations
+		workload.MinReadySeconds = replicaSet.Spec.MinReadySeconds
+		if replicaSet.Spec.Replicas != nil {
+			workload.Replicas = *replicaSet.Spec.Replicas
+		}
+		selector = replicaSet.Spec.Selector
+	default:
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(gvk)
+		if err := c.Get(ctx, key, object); err != nil {
+			return Workload{}, err
+		}
+		workload.Object = object
+		workload.Annotations = object.GetAnnotations()
+		minReadySeconds, _, _ := unstructured.NestedInt64(object.Object, "spec", "minReadySeconds")
+		workload.MinReadySeconds = int32(minReadySeconds)
+
+		s, err := scales.Scales(namespace).Get(ctx, workload.GroupResource, name, metav1.GetOptions{})
+		if err != nil {
+			return Workload{}, err
+		}
+		workload.Replicas = s.Spec.Replicas
+		workload.Selector, err = labels.Parse(s.Status.Selector)
+		if err != nil {
+			return Workload{}, err
+		}
+		return workload, nil
+	}
+
+	workload.Selector, err = metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return Workload{}, err
+	}
+	return workload, nil
+}
+
+// PodRevision returns the revision of the workload the pod was created from, or "" if it is unknown
+func PodRevision(pod corev1.Pod) string {
+	for _, label := range podRevisionLabels {
+		if revision, ok := pod.ObjectMeta.Labels[label]; ok {
+			return revision
+		}
+	}
+	return ""
+}
+
+// SortPodsByOrdinal sorts StatefulSet pods by the ordinal at the end of their names
+func SortPodsByOrdinal(pods []corev1.Pod, descending bool) {
+	ordinal := func(pod corev1.Pod) int {
+		name := pod.ObjectMeta.Name
+		i, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+		if err != nil {
+			return -1
+		}
+		return i
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		if descending {
+			return ordinal(pods[i]) > ordinal(pods[j])
+		}
+		return ordinal(pods[i]) < ordinal(pods[j])
+	})
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	migrationResultSucceeded = "succeeded"
+	migrationResultFailed    = "failed"
+	migrationResultTimeout   = "timeout"
+	migrationResultBlocked   = "blocked"
+)
+
+var (
+	podsMigratedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "baton_pods_migrated_total",
+			Help: "Number of pods Baton tried to migrate, partitioned by strategy and result",
+		},
+		[]string{"namespace", "name", "strategy", "result"},
+	)
+	cordonDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "baton_cordon_duration_seconds",
+			Help:    "How long nodes stayed cordoned while Baton rebalanced a strategy",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		},
+		[]string{"namespace", "name", "strategy"},
+	)
+	monitorDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "baton_monitor_duration_seconds",
+			Help:    "How long Baton waited for the replacement of a migrated pod",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		},
+		[]string{"namespace", "name"},
+	)
+	monitorTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "baton_monitor_timeouts_total",
+			Help: "Number of replacements that were not ready before monitorTimeoutSec",
+		},
+		[]string{"namespace", "name"},
+	)
+	validationFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "baton_validation_failures_total",
+			Help: "Number of runs which failed validation",
+		},
+		[]string{"namespace", "name"},
+	)
+	strategyDesiredPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "baton_strategy_desired_pods",
+			Help: "Number of pods a strategy is expected to keep",
+		},
+		[]string{"namespace", "name", "strategy"},
+	)
+	strategyCurrentPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "baton_strategy_current_pods",
+			Help: "Number of pods currently scheduled on the nodes of a strategy",
+		},
+		[]string{"namespace", "name", "strategy"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		podsMigratedTotal,
+		cordonDurationSeconds,
+		monitorDurationSeconds,
+		monitorTimeoutsTotal,
+		validationFailuresTotal,
+		strategyDesiredPods,
+		strategyCurrentPods,
+	)
+}
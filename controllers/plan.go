@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"context"
+
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	batonv1 "trsnium.com/baton/api/v1"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+const (
+	migrationKindSuplus = "Suplus"
+	migrationKindLess   = "Less"
+	// migrationKindEvacuation moves every pod off the nodes which signal their termination
+	migrationKindEvacuation = "Evacuation"
+)
+
+// migration is a planned move of pods off the cordoned nodes
+type migration struct {
+	// strategy is the strategy which has suplus or less pods
+	strategy    batonv1.Strategy
+	kind        string
+	cordonNodes []corev1.Node
+	pods        []corev1.Pod
+	targetNodes []corev1.Node
+	// targetStrategies are the strategies whose nodes the pods are steered to
+	targetStrategies []batonv1.Strategy
+}
+
+// planEvacuationMigrations plans to move every pod off the terminating nodes of each strategy, onto any node
+// of the strategies which is not terminating. The pods usually land on the healthy nodes of the same strategy,
+// and the regular migrations rebalance the strategies afterwards.
+func (r *BatonStrategiesyRunner) planEvacuationMigrations(
+	ctx context.Context,
+	strategies []batonv1.Strategy,
+	workload k8s.Workload,
+) []migration {
+	migrations := []migration{}
+	if r.baton.Spec.Evacuation == nil {
+		return migrations
+	}
+
+	for _, strategy := range strategies {
+		nodes, err := strategy.GetMatchNodes(ctx, r.client)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+		terminatingNodes := k8s.FilterNodes(nodes, func(n corev1.Node) bool {
+			return r.getNodeTerminationSignal(n) != ""
+		})
+		if len(terminatingNodes) == 0 {
+			continue
+		}
+
+		pods, err := strategy.GetPodsScheduledNodes(ctx, r.client, workload)
+		if err != nil {
+			r.logger.Error(err, "failed to list Pods")
+			continue
+		}
+		pods = k8s.FilterPods(pods, func(p corev1.Pod) bool {
+			return p.ObjectMeta.DeletionTimestamp == nil && containsNode(terminatingNodes, p.Spec.NodeName)
+		})
+		if len(pods) == 0 {
+			continue
+		}
+		for _, node := range terminatingNodes {
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "NodeTerminating", "evacuating Node %s signalled by %s", node.ObjectMeta.Name, r.getNodeTerminationSignal(node))
+		}
+
+		targetNodes, err := batonv1.GetStrategiesMatchNodes(ctx, r.client, strategies)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+		targetNodes = r.excludeTerminatingNodes(targetNodes)
+		if len(targetNodes) == 0 {
+			r.logger.Info(fmt.Sprintf("no Node to evacuate %s group (%s) to", migrationKindEvacuation, strategy))
+			continue
+		}
+
+		migrations = append(migrations, migration{
+			strategy:         strategy,
+			kind:             migrationKindEvacuation,
+			cordonNodes:      terminatingNodes,
+			pods:             pods,
+			targetNodes:      targetNodes,
+			targetStrategies: strategies,
+		})
+	}
+	return migrations
+}
+
+// planSuplusMigrations plans to move the pods beyond keepPods off the nodes of each strategy
+func (r *BatonStrategiesyRunner) planSuplusMigrations(
+	ctx context.Context,
+	strategies []batonv1.Strategy,
+	workload k8s.Workload,
+) []migration {
+	migrations := []migration{}
+	for i, strategy := range strategies {
+		pods, err := strategy.GetPodsScheduledNodes(ctx, r.client, workload)
+		if err != nil {
+			r.logger.Error(err, "failed to list Pods")
+			continue
+		}
+
+		if !strategy.IsSuplus(pods) {
+			continue
+		}
+		if workload.IsStatefulSet() {
+			// the highest ordinals are migrated first, so that the first pods move as late as possible
+			k8s.SortPodsByOrdinal(pods, false)
+		}
+
+		cordonNodes, err := strategy.GetMatchNodes(ctx, r.client)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+
+		victims := pods[strategy.GetKeepPods():]
+		if strategy.TopologyKey != "" {
+			// the pods kept by the strategy stay spread across its domains
+			victims = chooseSpreadVictims(pods, getNodeDomains(cordonNodes, strategy.TopologyKey), len(victims))
+		}
+
+		otherStrategies := []batonv1.Strategy{}
+		for j, s := range strategies {
+			if i != j {
+				otherStrategies = append(otherStrategies, s)
+			}
+		}
+		targetNodes, err := batonv1.GetStrategiesMatchNodes(ctx, r.client, otherStrategies)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+
+		migrations = append(migrations, migration{
+			strategy:         strategy,
+			kind:             migrationKindSuplus,
+			cordonNodes:      cordonNodes,
+			pods:             victims,
+			targetNodes:      r.excludeTerminatingNodes(excludeNodes(targetNodes, cordonNodes)),
+			targetStrategies: otherStrategies,
+		})
+	}
+	return migrations
+}
+
+// planLessMigrations plans to move pods from the strategies with suplus pods, or without keepPods,
+// onto the nodes of each strategy which has less pods than keepPods.
+// The excluded pods are not chosen, since they are already planned to move.
+func (r *BatonStrategiesyRunner) planLessMigrations(
+	ctx context.Context,
+	strategies []batonv1.Strategy,
+	workload k8s.Workload,
+	excludedPods []corev1.Pod,
+) []migration {
+	migrations := []migration{}
+	for _, strategy := range strategies {
+		pods, err := strategy.GetPodsScheduledNodes(ctx, r.client, workload)
+		if err != nil {
+			r.logger.Error(err, "failed to list Pods")
+			continue
+		}
+
+		if !strategy.IsLess(pods) {
+			continue
+		}
+
+		suplusStrategies := batonv1.FilterStrategies(strategies, func(s batonv1.Strategy) bool {
+			if s.IsRest() {
+				return true
+			}
+			isSuplus, _ := s.IsSuplusWithPodsScheduledNodes(ctx, r.client, workload)
+			return isSuplus
+		})
+
+		deleatablePods, err := batonv1.GetStrategiesPodsScheduledNodes(ctx, r.client, workload, suplusStrategies)
+		if err != nil {
+			r.logger.Error(err, "failed to list Pods")
+			continue
+		}
+		deleatablePods = k8s.FilterPods(deleatablePods, func(p corev1.Pod) bool {
+			return !containsPod(excludedPods, p)
+		})
+		if workload.IsStatefulSet() {
+			k8s.SortPodsByOrdinal(deleatablePods, true)
+		}
+
+		lessPods := int(strategy.GetKeepPods()) - len(pods)
+		if lessPods > len(deleatablePods) {
+			lessPods = len(deleatablePods)
+		}
+		if lessPods <= 0 {
+			continue
+		}
+
+		cordonNodes, err := batonv1.GetStrategiesMatchNodes(ctx, r.client, suplusStrategies)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+
+		targetNodes, err := strategy.GetMatchNodes(ctx, r.client)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+		// a strategy whose nodes are all terminating is not filled up again
+		targetNodes = r.excludeTerminatingNodes(excludeNodes(targetNodes, cordonNodes))
+		if len(targetNodes) == 0 {
+			continue
+		}
+
+		migrations = append(migrations, migration{
+			strategy:         strategy,
+			kind:             migrationKindLess,
+			cordonNodes:      cordonNodes,
+			pods:             deleatablePods[:lessPods],
+			targetNodes:      targetNodes,
+			targetStrategies: []batonv1.Strategy{strategy},
+		})
+	}
+	return migrations
+}
+
+// recordPlan publishes the migrations Baton would make as Events instead of carrying them out
+func (r *BatonStrategiesyRunner) recordPlan(migrations []migration) {
+	steering := "cordon"
+	switch r.baton.Spec.SteeringMethod {
+	case batonv1.SteeringMethodTaint:
+		steering = "taint"
+	case batonv1.SteeringMethodNodeAffinity:
+		steering = "steer new pods off"
+	}
+	for _, m := range migrations {
+		plan := toMigrationPlan(m)
+		message := fmt.Sprintf(
+			"would %s Nodes %v and evict Pods %v to Nodes %v for %s group (%s)",
+			steering, plan.CordonNodes, plan.EvictPods, plan.TargetNodes, m.kind, m.strategy,
+		)
+		r.logger.Info("dry run: " + message)
+		r.recorder.Event(r.baton, corev1.EventTypeNormal, "DryRun", message)
+	}
+}
+
+func toMigrationPlans(migrations []migration) []batonv1.MigrationPlan {
+	plans := []batonv1.MigrationPlan{}
+	for _, m := range migrations {
+		plans = append(plans, toMigrationPlan(m))
+	}
+	return plans
+}
+
+func toMigrationPlan(m migration) batonv1.MigrationPlan {
+	plan := batonv1.MigrationPlan{
+		Strategy: m.strategy.String(),
+		Type:     m.kind,
+	}
+	for _, node := range m.cordonNodes {
+		plan.CordonNodes = append(plan.CordonNodes, node.ObjectMeta.Name)
+	}
+	for _, pod := range m.pods {
+		plan.EvictPods = append(plan.EvictPods, pod.ObjectMeta.Name)
+	}
+	for _, node := range m.targetNodes {
+		plan.TargetNodes = append(plan.TargetNodes, node.ObjectMeta.Name)
+	}
+	return plan
+}
+
+func getMigratingPods(migrations []migration) []corev1.Pod {
+	pods := []corev1.Pod{}
+	for _, m := range migrations {
+		pods = append(pods, m.pods...)
+	}
+	return pods
+}
+
+func excludeNodes(nodes []corev1.Node, excludedNodes []corev1.Node) []corev1.Node {
+	return k8s.FilterNodes(nodes, func(n corev1.Node) bool {
+		for _, excludedNode := range excludedNodes {
+			if excludedNode.ObjectMeta.Name == n.ObjectMeta.Name {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// excludeTerminatingNodes drops the nodes which signal their termination, since pods must not be migrated onto them
+func (r *BatonStrategiesyRunner) excludeTerminatingNodes(nodes []corev1.Node) []corev1.Node {
+	return k8s.FilterNodes(nodes, func(n corev1.Node) bool {
+		return r.getNodeTerminationSignal(n) == ""
+	})
+}
+
+func containsPod(pods []corev1.Pod, pod corev1.Pod) bool {
+	for _, p := range pods {
+		if p.ObjectMeta.UID == pod.ObjectMeta.UID {
+			return true
+		}
+	}
+	return false
+}
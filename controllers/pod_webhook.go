@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	batonv1 "trsnium.com/baton/api/v1"
+)
+
+// SteeredByAnnotation records which Baton injected the node affinity into a pod
+const SteeredByAnnotation = "baton.baton/steered-by"
+
+// SteeringLabel opts the pods of a workload in to the pod webhook. Only pods labeled with
+// SteeringLabelEnabled are sent to the controller, see the objectSelector in config/webhook/manifests.yaml.
+const (
+	SteeringLabel        = "baton.baton/steering"
+	SteeringLabelEnabled = "enabled"
+)
+
+// The objectSelector, namespaceSelector and timeoutSeconds of the webhook are not supported by the marker,
+// and are maintained in config/webhook/manifests.yaml.
+// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,groups="",resources=pods,verbs=create,versions=v1,name=mpod.baton.kb.io
+
+// PodSteerer injects a required node affinity into the new pods of a workload
+// while its Baton steers them by NodeAffinity. It reads the steering from the
+// status of the Batons, so every replica of the controller serves the same answer.
+type PodSteerer struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+func (r *PodSteerer) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	err := r.decoder.Decode(req, pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	batons := batonv1.BatonList{}
+	err = r.Client.List(ctx, &batons)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for _, baton := range batons.Items {
+		steering := baton.Status.Steering
+		if steering == nil || steering.Namespace != req.Namespace {
+			continue
+		}
+		podSelector, err := labels.Parse(steering.PodSelector)
+		if err != nil || !podSelector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+
+		steerPod(pod, steering.NodeSelectorTerms)
+		if pod.ObjectMeta.Annotations == nil {
+			pod.ObjectMeta.Annotations = map[string]string{}
+		}
+		pod.ObjectMeta.Annotations[SteeredByAnnotation] = cordonOwner(baton)
+
+		marshaledPod, err := json.Marshal(pod)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	}
+	return admission.Allowed("")
+}
+
+// InjectDecoder injects the decoder
+func (r *PodSteerer) InjectDecoder(d *admission.Decoder) error {
+	r.decoder = d
+	return nil
+}
+
+// steerPod requires the pod to be scheduled to a node matching any of the terms.
+// The terms of a node affinity are ORed, so the requirements of each term are
+// combined with every term the pod already requires.
+func steerPod(pod *corev1.Pod, terms []corev1.NodeSelectorTerm) {
+	if len(terms) == 0 {
+		return
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+
+	existingTerms := required.NodeSelectorTerms
+	if len(existingTerms) == 0 {
+		existingTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	steeredTerms := []corev1.NodeSelectorTerm{}
+	for _, existingTerm := range existingTerms {
+		for _, term := range terms {
+			steeredTerm := *existingTerm.DeepCopy()
+			steeredTerm.MatchExpressions = append(steeredTerm.MatchExpressions, term.MatchExpressions...)
+			steeredTerms = append(steeredTerms, steeredTerm)
+		}
+	}
+	required.NodeSelectorTerms = steeredTerms
+}
@@ -1,30 +1,91 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-logr/logr"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	batonv1 "trsnium.com/baton/api/v1"
+	k8s "trsnium.com/baton/controllers/kubernetes"
 )
 
 type BatonStrategiesRunnerManager struct {
 	client                   client.Client
-	batonStrategiesRunnerMap map[string]BatonStrategiesyRunner
-	logger                   logr.Logger
+	clientset                kubernetes.Interface
+	scales                   scale.ScalesGetter
+	mapper                   meta.RESTMapper
+	recorder                 record.EventRecorder
+	dryRun                   bool
+	nodeLocker               *nodeLocker
+	batonStrategiesRunnerMap map[string]*BatonStrategiesyRunner
+	// mutex guards batonStrategiesRunnerMap, which is also read by the informer event handlers
+	mutex sync.RWMutex
+	// ctx is the parent of the contexts of the runners, cancelled when the manager shuts down
+	ctx    context.Context
+	cancel context.CancelFunc
+	logger logr.Logger
 }
 
-func NewBatonStrategiesyRunnerManager(client client.Client, logger logr.Logger) *BatonStrategiesRunnerManager {
+func NewBatonStrategiesyRunnerManager(
+	client client.Client,
+	clientset kubernetes.Interface,
+	scales scale.ScalesGetter,
+	mapper meta.RESTMapper,
+	recorder record.EventRecorder,
+	dryRun bool,
+	logger logr.Logger,
+) *BatonStrategiesRunnerManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &BatonStrategiesRunnerManager{
 		client:                   client,
-		batonStrategiesRunnerMap: make(map[string]BatonStrategiesyRunner),
+		clientset:                clientset,
+		scales:                   scales,
+		mapper:                   mapper,
+		recorder:                 recorder,
+		dryRun:                   dryRun,
+		nodeLocker:               newNodeLocker(),
+		batonStrategiesRunnerMap: make(map[string]*BatonStrategiesyRunner),
+		ctx:                      ctx,
+		cancel:                   cancel,
 		logger:                   logger.WithName("BatonStrategiesRunnerManager"),
 	}
 }
 
+// Start implements manager.Runnable. It blocks until the manager shuts down,
+// then stops every runner and waits for them to release the nodes they hold.
+func (r *BatonStrategiesRunnerManager) Start(stop <-chan struct{}) error {
+	<-stop
+	r.cancel()
+
+	r.mutex.Lock()
+	runners := r.batonStrategiesRunnerMap
+	r.batonStrategiesRunnerMap = make(map[string]*BatonStrategiesyRunner)
+	r.mutex.Unlock()
+
+	for key, runner := range runners {
+		runner.Stop()
+		runner.Wait()
+		r.logger.Info(fmt.Sprintf("%s is Stoped", key))
+	}
+	return nil
+}
+
 func (r *BatonStrategiesRunnerManager) IsManaged(baton batonv1.Baton) bool {
 	metadata := baton.ObjectMeta
 	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 	_, isManaged := r.batonStrategiesRunnerMap[key]
 	return isManaged
 }
@@ -32,47 +93,93 @@ func (r *BatonStrategiesRunnerManager) IsManaged(baton batonv1.Baton) bool {
 func (r *BatonStrategiesRunnerManager) IsUpdated(baton batonv1.Baton) bool {
 	metadata := baton.ObjectMeta
 	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
-	batonStrategiesRunner := r.batonStrategiesRunnerMap[key]
-	return batonStrategiesRunner.IsUpdatedBatonStrategies(baton)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	batonStrategiesRunner, isManaged := r.batonStrategiesRunnerMap[key]
+	return isManaged && batonStrategiesRunner.IsUpdatedBatonStrategies(baton)
+}
+
+// SetSuspended suspends or resumes the runner of the Baton following its spec and pause annotation
+func (r *BatonStrategiesRunnerManager) SetSuspended(baton batonv1.Baton) {
+	metadata := baton.ObjectMeta
+	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
+	r.mutex.RLock()
+	batonStrategiesRunner, isManaged := r.batonStrategiesRunnerMap[key]
+	r.mutex.RUnlock()
+	if !isManaged {
+		return
+	}
+	batonStrategiesRunner.SetSuspended(baton.IsSuspended())
+}
+
+// Update hands the updated Baton to its runner, which applies it once the current run is finished
+func (r *BatonStrategiesRunnerManager) Update(baton batonv1.Baton) {
+	metadata := baton.ObjectMeta
+	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
+	r.mutex.RLock()
+	batonStrategiesRunner, isManaged := r.batonStrategiesRunnerMap[key]
+	r.mutex.RUnlock()
+	if !isManaged {
+		return
+	}
+	batonStrategiesRunner.Update(baton)
 }
 
 func (r *BatonStrategiesRunnerManager) Add(baton batonv1.Baton) {
 	metadata := baton.ObjectMeta
 	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
-	batonStrategiesRunner := NewBatonStrategiesyRunner(r.client, baton, r.logger, key)
-	batonStrategiesRunner.Run()
+	batonStrategiesRunner := NewBatonStrategiesyRunner(r.client, r.clientset, r.scales, r.mapper, r.recorder, baton, r.dryRun, r.nodeLocker, r.logger, key)
+	batonStrategiesRunner.Run(r.ctx)
+	r.mutex.Lock()
+	previousRunner, isManaged := r.batonStrategiesRunnerMap[key]
 	r.batonStrategiesRunnerMap[key] = batonStrategiesRunner
+	r.mutex.Unlock()
+	if isManaged {
+		previousRunner.Stop()
+	}
 	r.logger.Info(fmt.Sprintf("%s is Started", key))
+	r.recorder.Event(&baton, corev1.EventTypeNormal, "Started", "runner is started")
 }
 
 func (r *BatonStrategiesRunnerManager) Delete(baton batonv1.Baton) {
 	metadata := baton.ObjectMeta
 	key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
-	batonRunner := r.batonStrategiesRunnerMap[key]
-	batonRunner.Stop()
+	r.delete(key)
+}
+
+func (r *BatonStrategiesRunnerManager) delete(key string) {
+	r.mutex.Lock()
+	batonRunner, isManaged := r.batonStrategiesRunnerMap[key]
 	delete(r.batonStrategiesRunnerMap, key)
+	r.mutex.Unlock()
+	if !isManaged {
+		return
+	}
+
+	// the runner finishes in the background, releasing what it holds
+	batonRunner.Stop()
 	r.logger.Info(fmt.Sprintf("%s is Stoped", key))
 }
 
 func (r *BatonStrategiesRunnerManager) DeleteNotExists(batons *batonv1.BatonList) {
 	expectedKeys := []string{}
-	batonMap := make(map[string]batonv1.Baton)
 	for _, baton := range batons.Items {
 		metadata := baton.ObjectMeta
 		key := fmt.Sprintf("%s-%s", metadata.Namespace, metadata.Name)
 		expectedKeys = append(expectedKeys, key)
-		batonMap[key] = baton
 	}
 
 	batonStrategiesRunneKeys := r.getBatonStrategiesRunnerKeys()
 	for _, batonStrategiesRunneKey := range batonStrategiesRunneKeys {
 		if !contains(expectedKeys, batonStrategiesRunneKey) {
-			r.Delete(batonMap[batonStrategiesRunneKey])
+			r.delete(batonStrategiesRunneKey)
 		}
 	}
 }
 
 func (r *BatonStrategiesRunnerManager) getBatonStrategiesRunnerKeys() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 	ks := []string{}
 	for k, _ := range r.batonStrategiesRunnerMap {
 		ks = append(ks, k)
@@ -80,6 +187,140 @@ func (r *BatonStrategiesRunnerManager) getBatonStrategiesRunnerKeys() []string {
 	return ks
 }
 
+// triggerRunners triggers the runners which watch the changed object
+func (r *BatonStrategiesRunnerManager) triggerRunners(isWatching func(*BatonStrategiesyRunner) bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, batonStrategiesRunner := range r.batonStrategiesRunnerMap {
+		if isWatching(batonStrategiesRunner) {
+			batonStrategiesRunner.Trigger()
+		}
+	}
+}
+
+// ReleaseCordons uncordons and untaints every node the Baton still holds
+func (r *BatonStrategiesRunnerManager) ReleaseCordons(ctx context.Context, baton batonv1.Baton) error {
+	return releaseCordons(ctx, r.client, r.nodeLocker, baton)
+}
+
+// ReleaseOrphanedCordons uncordons and untaints the nodes held by Batons which no longer exist
+func (r *BatonStrategiesRunnerManager) ReleaseOrphanedCordons(ctx context.Context, batons *batonv1.BatonList) error {
+	owners := []string{}
+	taintKeys := []string{}
+	for _, baton := range batons.Items {
+		owners = append(owners, cordonOwner(baton))
+		taintKeys = append(taintKeys, steeringTaint(baton).Key)
+	}
+
+	nodes, err := k8s.ListNodes(ctx, r.client)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		for _, taint := range nodes[i].Spec.Taints {
+			if !strings.HasPrefix(taint.Key, k8s.SteeringTaintKeyPrefix) || contains(taintKeys, taint.Key) {
+				continue
+			}
+
+			r.logger.Info(fmt.Sprintf("remove %s left on Node{Name: %s}", taint.Key, nodes[i].ObjectMeta.Name))
+			_, err := k8s.UntaintNode(ctx, r.client, nodes[i].ObjectMeta.Name, taint.Key)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, owner := range k8s.GetCordonOwners(nodes[i]) {
+			if contains(owners, owner) {
+				continue
+			}
+
+			r.logger.Info(fmt.Sprintf("release the cordon of Node{Name: %s} left by Baton %s", nodes[i].ObjectMeta.Name, owner))
+			unlock := r.nodeLocker.lock(nodes[i].ObjectMeta.Name)
+			_, err := k8s.UncordonNode(ctx, r.client, nodes[i].ObjectMeta.Name, owner)
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cordonOwner is the value of the cordoned-by annotation for the Baton
+func cordonOwner(baton batonv1.Baton) string {
+	return fmt.Sprintf("%s/%s", baton.ObjectMeta.Namespace, baton.ObjectMeta.Name)
+}
+
+// steeringTaint is the NoSchedule taint unique to the Baton. The name part of the key is
+// limited to 63 characters, so long names are shortened with a hash to stay unique.
+func steeringTaint(baton batonv1.Baton) corev1.Taint {
+	name := fmt.Sprintf("%s.%s", baton.ObjectMeta.Namespace, baton.ObjectMeta.Name)
+	if len(name) > validation.LabelValueMaxLength {
+		hash := fnv.New32a()
+		hash.Write([]byte(name))
+		suffix := fmt.Sprintf("-%08x", hash.Sum32())
+		name = name[:validation.LabelValueMaxLength-len(suffix)] + suffix
+	}
+	return corev1.Taint{
+		Key:    k8s.SteeringTaintKeyPrefix + name,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+}
+
+// releaseCordons uncordons and untaints every node the Baton still holds
+func releaseCordons(ctx context.Context, c client.Client, locker *nodeLocker, baton batonv1.Baton) error {
+	nodes, err := k8s.ListNodes(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	owner := cordonOwner(baton)
+	taint := steeringTaint(baton)
+	for i := range nodes {
+		if contains(k8s.GetCordonOwners(nodes[i]), owner) {
+			unlock := locker.lock(nodes[i].ObjectMeta.Name)
+			_, err := k8s.UncordonNode(ctx, c, nodes[i].ObjectMeta.Name, owner)
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
+		if k8s.HasTaint(nodes[i], taint) {
+			_, err := k8s.UntaintNode(ctx, c, nodes[i].ObjectMeta.Name, taint.Key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nodeLocker serializes the changes the runners make to the same node,
+// so concurrent runners do not race on its cordon owners
+type nodeLocker struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newNodeLocker() *nodeLocker {
+	return &nodeLocker{locks: make(map[string]*sync.Mutex)}
+}
+
+// lock locks the node and returns the function unlocking it
+func (l *nodeLocker) lock(nodeName string) func() {
+	l.mutex.Lock()
+	lock, isLocked := l.locks[nodeName]
+	if !isLocked {
+		lock = &sync.Mutex{}
+		l.locks[nodeName] = lock
+	}
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
 func contains(s []string, e string) bool {
 	for _, v := range s {
 		if e == v {
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	batonv1 "trsnium.com/baton/api/v1"
+)
+
+func TestRunnerManagerAddUpdateDelete(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	manager := cluster.newManager()
+	stop := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- manager.Start(stop)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	baton := cluster.baton
+	manager.Add(baton)
+	if !manager.IsManaged(baton) {
+		t.Fatal("Baton is not managed after Add")
+	}
+	if manager.IsUpdated(baton) {
+		t.Error("Baton is updated without a new generation")
+	}
+
+	updated := *baton.DeepCopy()
+	updated.ObjectMeta.Generation = 2
+	updated.Spec.IntervalSec = 1800
+	if !manager.IsUpdated(updated) {
+		t.Error("Baton of a new generation is not updated")
+	}
+	manager.Update(updated)
+	if manager.IsUpdated(updated) {
+		t.Error("Baton is still updated after Update")
+	}
+
+	// the Baton is added again by a reconcile racing with the informer
+	manager.Add(updated)
+	manager.SetSuspended(updated)
+	manager.Delete(updated)
+	if manager.IsManaged(updated) {
+		t.Error("Baton is still managed after Delete")
+	}
+}
+
+func TestRunnerManagerStartStopsRunners(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	manager := cluster.newManager()
+	stop := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- manager.Start(stop)
+	}()
+
+	manager.Add(cluster.baton)
+	select {
+	case <-cluster.evicted:
+	case <-time.After(10 * time.Second):
+		t.Error("no Pod was evicted")
+	}
+
+	close(stop)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(cleanupTimeout):
+		t.Fatal("manager did not stop")
+	}
+	if manager.IsManaged(cluster.baton) {
+		t.Error("Baton is still managed after the manager stopped")
+	}
+	cluster.expectUncordoned(t, "node-a")
+}
@@ -1,308 +1,1265 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/go-logr/logr"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
 	"time"
 	batonv1 "trsnium.com/baton/api/v1"
 	k8s "trsnium.com/baton/controllers/kubernetes"
 )
 
+var (
+	errMonitorTimeout = errors.New("time out to monitor new pod")
+	// errPodUnschedulable means the replacement of a migrated pod fits on none of the schedulable nodes
+	errPodUnschedulable = errors.New("new pod is unschedulable")
+	// errPodFailed means the replacement of a migrated pod can not become ready, e.g. CrashLoopBackOff
+	errPodFailed = errors.New("new pod failed")
+	// errPodMisplaced means the replacement of a migrated pod was scheduled outside of the target nodes
+	errPodMisplaced = errors.New("new pod is scheduled outside of the target nodes")
+	// errSuspended means the runner stopped between migrations because the Baton was suspended
+	errSuspended = errors.New("baton is suspended")
+	// errOutsideSchedule means the runner stopped between migrations because the window of spec.schedule closed
+	errOutsideSchedule = errors.New("outside of the schedule")
+	// errMigrationAborted means a migration was stopped by spec.failurePolicy, so the rest of the run is skipped
+	errMigrationAborted = errors.New("aborted the migration")
+)
+
+const (
+	// triggerDebounce gathers the bursts of pod and node events into a single run
+	triggerDebounce = 5 * time.Second
+	// monitorInterval is how often the replacement of a migrated pod is checked. The pods are read from the cache.
+	monitorInterval = 2 * time.Second
+	// cleanupTimeout bounds the release of nodes and replicas after the runner is stopped in the middle of a migration
+	cleanupTimeout = 30 * time.Second
+)
+
 type BatonStrategiesyRunner struct {
-	client   client.Client
-	baton    *batonv1.Baton
-	stopFlag chan bool
+	client    client.Client
+	clientset kubernetes.Interface
+	scales    scale.ScalesGetter
+	mapper    meta.RESTMapper
+	recorder  record.EventRecorder
+	baton     *batonv1.Baton
+	dryRun    bool
+	// nodeLocker is shared by the runners of the manager
+	nodeLocker *nodeLocker
+	trigger    chan struct{}
+	// evacuate runs the strategies right away, without the debounce of trigger
+	evacuate chan struct{}
 	logger   logr.Logger
+
+	// pendingBaton is an updated Baton the runner applies before its next run, so that a
+	// migration in progress is not interrupted. batonMutex guards it and the swap of baton.
+	batonMutex   sync.RWMutex
+	pendingBaton *batonv1.Baton
+	// suspended is set by spec.suspend or the pause annotation, and guarded by batonMutex as well
+	suspended bool
+	// migrationLimiter paces the evictions by spec.migrationsPerMinute across runs, and is nil without a limit
+	migrationLimiter flowcontrol.RateLimiter
+	// schedule is spec.schedule compiled when the Baton is applied, and is nil without a schedule
+	schedule    *batonv1.CompiledSchedule
+	scheduleErr error
+
+	// cancel stops the runner, and done is closed once it has stopped
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// the workload observed by the last run, used to tell which pods the runner watches
+	workloadMutex     sync.RWMutex
+	workloadNamespace string
+	workloadSelector  labels.Selector
 }
 
-func NewBatonStrategiesyRunner(client client.Client, baton batonv1.Baton, logger logr.Logger, runnerName string) BatonStrategiesyRunner {
-	return BatonStrategiesyRunner{
-		client: client,
-		baton:  &baton,
-		logger: logger.WithName("BatonStrategiesRunnerManager").WithName(runnerName),
+func NewBatonStrategiesyRunner(
+	client client.Client,
+	clientset kubernetes.Interface,
+	scales scale.ScalesGetter,
+	mapper meta.RESTMapper,
+	recorder record.EventRecorder,
+	baton batonv1.Baton,
+	dryRun bool,
+	nodeLocker *nodeLocker,
+	logger logr.Logger,
+	runnerName string,
+) *BatonStrategiesyRunner {
+	schedule, scheduleErr := compileSchedule(baton)
+	return &BatonStrategiesyRunner{
+		client:            client,
+		clientset:         clientset,
+		scales:            scales,
+		mapper:            mapper,
+		recorder:          recorder,
+		baton:             &baton,
+		suspended:         baton.IsSuspended(),
+		migrationLimiter:  newMigrationLimiter(baton),
+		schedule:          schedule,
+		scheduleErr:       scheduleErr,
+		dryRun:            dryRun,
+		nodeLocker:        nodeLocker,
+		trigger:           make(chan struct{}, 1),
+		evacuate:          make(chan struct{}, 1),
+		logger:            logger.WithName("BatonStrategiesRunnerManager").WithName(runnerName),
+		workloadNamespace: baton.GetWorkloadRef().Namespace,
 	}
 }
 
-func (r *BatonStrategiesyRunner) Run() {
+// Run starts running the strategies until ctx is cancelled or the runner is stopped
+func (r *BatonStrategiesyRunner) Run(ctx context.Context) {
 	r.logger.Info("Run runner")
-	r.stopFlag = make(chan bool)
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
 	go func() {
-		for {
-			err := r.runStrategies()
+		defer func() {
+			r.deleteMetrics()
+			close(r.done)
+		}()
+
+		// nodes left cordoned by a previous controller process are released before the first run
+		if !r.isDryRun() {
+			err := releaseCordons(ctx, r.client, r.nodeLocker, *r.baton)
 			if err != nil {
-				r.logger.Error(err, "failed to run strategy")
+				r.logger.Error(err, "failed to release leftover cordons")
+			}
+			r.clearSteering(ctx)
+		}
+
+		suspendedCondition := batonv1.FindCondition(r.baton.Status.Conditions, batonv1.ConditionSuspended)
+		paused := suspendedCondition != nil && suspendedCondition.Status == metav1.ConditionTrue
+		for {
+			if r.applyPendingBaton() {
+				r.logger.Info(fmt.Sprintf("applied generation %d", r.baton.ObjectMeta.Generation))
+				r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Updated", "applied the spec of generation %d", r.baton.ObjectMeta.Generation)
+			}
+
+			if suspended := r.isSuspended(); suspended != paused {
+				if err := r.recordSuspended(ctx, suspended); err != nil {
+					r.logger.Error(err, "failed to update suspended condition")
+				} else {
+					paused = suspended
+				}
+			}
+
+			if !paused {
+				err := r.runStrategies(ctx)
+				if err != nil {
+					r.logger.Error(err, "failed to run strategy")
+				}
+			}
+			// the evictions and replacements of the run trigger the runner itself, which would run again
+			// right away instead of waiting for the interval, e.g. while a migration keeps failing
+			select {
+			case <-r.trigger:
+			default:
 			}
 			select {
 			case <-time.After(time.Duration(r.baton.Spec.IntervalSec) * time.Second):
-			case <-r.stopFlag:
+			case <-r.trigger:
+				select {
+				case <-time.After(triggerDebounce):
+				case <-r.evacuate:
+				case <-ctx.Done():
+					return
+				}
+			case <-r.evacuate:
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 }
 
+// Trigger asks the runner to run the strategies without waiting for the interval
+func (r *BatonStrategiesyRunner) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Evacuate asks the runner to run the strategies right away, since one of its nodes is about to be terminated
+func (r *BatonStrategiesyRunner) Evacuate() {
+	select {
+	case r.evacuate <- struct{}{}:
+	default:
+	}
+}
+
+// IsNodeTerminating reports whether the node signals its termination by spec.evacuation of the runner
+func (r *BatonStrategiesyRunner) IsNodeTerminating(node *corev1.Node) bool {
+	r.batonMutex.RLock()
+	defer r.batonMutex.RUnlock()
+	return r.getNodeTerminationSignal(*node) != ""
+}
+
+// getNodeTerminationSignal returns why the node is about to be terminated, or "" if it is not or evacuation is disabled
+func (r *BatonStrategiesyRunner) getNodeTerminationSignal(node corev1.Node) string {
+	evacuation := r.baton.Spec.Evacuation
+	if evacuation == nil {
+		return ""
+	}
+	return k8s.GetNodeTerminationSignal(node, evacuation.Taints, evacuation.Annotations, evacuation.Conditions)
+}
+
+// IsWatchingPod reports whether the pod belongs to the workload of the runner
+func (r *BatonStrategiesyRunner) IsWatchingPod(pod *corev1.Pod) bool {
+	r.workloadMutex.RLock()
+	defer r.workloadMutex.RUnlock()
+	if pod.ObjectMeta.Namespace != r.workloadNamespace {
+		return false
+	}
+	return r.workloadSelector == nil || r.workloadSelector.Matches(labels.Set(pod.ObjectMeta.Labels))
+}
+
+// IsWatchingNode reports whether the node belongs to any of the strategies of the runner
+func (r *BatonStrategiesyRunner) IsWatchingNode(node *corev1.Node) bool {
+	r.batonMutex.RLock()
+	defer r.batonMutex.RUnlock()
+	for _, strategy := range r.baton.Spec.Strategies {
+		selector, err := strategy.Selector()
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(node.ObjectMeta.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *BatonStrategiesyRunner) observeWorkload(workload k8s.Workload) {
+	r.workloadMutex.Lock()
+	defer r.workloadMutex.Unlock()
+	r.workloadNamespace = workload.Namespace
+	r.workloadSelector = workload.Selector
+}
+
+// Stop stops the runner without waiting for it. A migration in progress is interrupted,
+// and the nodes and replicas it holds are released before the runner is done.
 func (r *BatonStrategiesyRunner) Stop() {
-	r.stopFlag <- true
+	r.cancel()
 	r.logger.Info("Stop runner")
 }
 
+// Wait blocks until the runner has stopped
+func (r *BatonStrategiesyRunner) Wait() {
+	<-r.done
+}
+
+// deleteMetrics drops the gauges of the strategies, so a stopped runner does not report stale drift
+func (r *BatonStrategiesyRunner) deleteMetrics() {
+	metadata := r.baton.ObjectMeta
+	for _, strategy := range r.baton.Spec.Strategies {
+		strategyDesiredPods.DeleteLabelValues(metadata.Namespace, metadata.Name, strategy.String())
+		strategyCurrentPods.DeleteLabelValues(metadata.Namespace, metadata.Name, strategy.String())
+	}
+}
+
+// isDryRun reports whether the Baton or the whole controller only plans the migrations
+func (r *BatonStrategiesyRunner) isDryRun() bool {
+	return r.dryRun || r.baton.Spec.DryRun
+}
+
+// IsUpdatedBatonStrategies reports whether the spec of the Baton changed since the one the runner has or is about to apply
 func (r *BatonStrategiesyRunner) IsUpdatedBatonStrategies(baton batonv1.Baton) bool {
-	isUpdatedDeploymentInfo := r.baton.Spec.Deployment == baton.Spec.Deployment
-	isUpdatedStrategies := true
-	return isUpdatedDeploymentInfo ||
-		isUpdatedStrategies ||
-		r.baton.Spec.IntervalSec != baton.Spec.IntervalSec
-}
-
-func (r *BatonStrategiesyRunner) runStrategies() error {
-	deploymentInfo := r.baton.Spec.Deployment
-	namespace := deploymentInfo.NameSpace
-	deploymentName := deploymentInfo.Name
-	deployment, err := k8s.GetDeployment(r.client, namespace, deploymentName)
-	if err != nil {
-		r.logger.Error(err, fmt.Sprintf("failed to get Deployment{Namespace: %s, Name: %s}", namespace, deploymentName))
-		return err
+	r.batonMutex.RLock()
+	defer r.batonMutex.RUnlock()
+	current := r.baton
+	if r.pendingBaton != nil {
+		current = r.pendingBaton
+	}
+	return baton.ObjectMeta.Generation != current.ObjectMeta.Generation
+}
+
+// Update hands the updated Baton to the runner, which applies it before its next run
+func (r *BatonStrategiesyRunner) Update(baton batonv1.Baton) {
+	r.batonMutex.Lock()
+	r.pendingBaton = &baton
+	r.batonMutex.Unlock()
+	r.Trigger()
+}
+
+// SetSuspended suspends or resumes the runner. A suspended runner finishes the pod it is migrating,
+// releases the nodes of the migration and skips the runs until it is resumed.
+func (r *BatonStrategiesyRunner) SetSuspended(suspended bool) {
+	r.batonMutex.Lock()
+	changed := r.suspended != suspended
+	r.suspended = suspended
+	r.batonMutex.Unlock()
+	if changed {
+		r.Trigger()
+	}
+}
+
+func (r *BatonStrategiesyRunner) isSuspended() bool {
+	r.batonMutex.RLock()
+	defer r.batonMutex.RUnlock()
+	return r.suspended
+}
+
+// isInSchedule reports whether spec.schedule allows migrations now
+func (r *BatonStrategiesyRunner) isInSchedule() (bool, error) {
+	if r.scheduleErr != nil {
+		return false, r.scheduleErr
+	}
+	if r.schedule == nil {
+		return true, nil
+	}
+	return r.schedule.IsAllowed(time.Now()), nil
+}
+
+// checkMigrationsAllowed returns why the runner can not go on to the next migration, or nil if it can
+func (r *BatonStrategiesyRunner) checkMigrationsAllowed() error {
+	if r.isSuspended() {
+		return errSuspended
+	}
+	if inSchedule, err := r.isInSchedule(); err == nil && !inSchedule {
+		return errOutsideSchedule
+	}
+	return nil
+}
+
+// checkMigrationAllowed is checkMigrationsAllowed for the migration. Evacuations go on outside of the schedule.
+func (r *BatonStrategiesyRunner) checkMigrationAllowed(m migration) error {
+	err := r.checkMigrationsAllowed()
+	if m.kind == migrationKindEvacuation && errors.Is(err, errOutsideSchedule) {
+		return nil
+	}
+	return err
+}
+
+// isInterrupted reports whether the migrations were stopped by a suspension or the schedule rather than a failure
+func isInterrupted(err error) bool {
+	return errors.Is(err, errSuspended) || errors.Is(err, errOutsideSchedule)
+}
+
+// recordSuspended reflects the suspension in the status and Events
+func (r *BatonStrategiesyRunner) recordSuspended(ctx context.Context, suspended bool) error {
+	if suspended {
+		r.logger.Info("suspended")
+		r.recorder.Event(r.baton, corev1.EventTypeNormal, "Suspended", "suspended by spec.suspend or the pause annotation")
+	} else {
+		r.logger.Info("resumed")
+		r.recorder.Event(r.baton, corev1.EventTypeNormal, "Resumed", "resumed running strategies")
+	}
+
+	return r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.ObservedGeneration = r.baton.ObjectMeta.Generation
+		if suspended {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionSuspended, metav1.ConditionTrue, "Paused", "suspended by spec.suspend or the pause annotation")
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionProgressing, metav1.ConditionFalse, "Suspended", "waiting to be resumed")
+			return
+		}
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionSuspended, metav1.ConditionFalse, "Resumed", "")
+	})
+}
+
+// applyPendingBaton swaps in the updated Baton, and reports whether there was one
+func (r *BatonStrategiesyRunner) applyPendingBaton() bool {
+	r.batonMutex.Lock()
+	defer r.batonMutex.Unlock()
+	if r.pendingBaton == nil {
+		return false
+	}
+
+	// the gauges of strategies which were removed or changed would stay otherwise
+	r.deleteMetrics()
+	if r.pendingBaton.Spec.MigrationsPerMinute != r.baton.Spec.MigrationsPerMinute {
+		r.migrationLimiter = newMigrationLimiter(*r.pendingBaton)
 	}
+	r.schedule, r.scheduleErr = compileSchedule(*r.pendingBaton)
+	r.baton = r.pendingBaton
+	r.pendingBaton = nil
+	return true
+}
+
+// compileSchedule parses spec.schedule of the Baton once, since it is evaluated before every batch
+func compileSchedule(baton batonv1.Baton) (*batonv1.CompiledSchedule, error) {
+	if baton.Spec.Schedule == nil {
+		return nil, nil
+	}
+	return baton.Spec.Schedule.Compile()
+}
 
-	err = batonv1.ValidateStrategies(r.client, deployment, r.baton.Spec.Strategies)
+func (r *BatonStrategiesyRunner) runStrategies(ctx context.Context) error {
+	err := r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.ObservedGeneration = r.baton.ObjectMeta.Generation
+		status.LastRunStartedAt = time.Now().Format(time.RFC3339)
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionProgressing, metav1.ConditionTrue, "Running", "running strategies")
+	})
 	if err != nil {
-		return err
+		r.logger.Error(err, "failed to update status")
 	}
 
-	err = r.migrateSuplusPodToOther(r.baton.Spec.Strategies, deployment)
+	workloadRef := r.baton.GetWorkloadRef()
+	workload, err := k8s.GetWorkload(
+		ctx,
+		r.client,
+		r.mapper,
+		r.scales,
+		workloadRef.GroupVersionKind(),
+		workloadRef.Namespace,
+		workloadRef.Name,
+	)
 	if err != nil {
-		r.logger.Error(err, "failed to migrate suplus Pod to other Node")
+		r.logger.Error(err, fmt.Sprintf("failed to get %s{Namespace: %s, Name: %s}", workloadRef.Kind, workloadRef.Namespace, workloadRef.Name))
+		return r.recordFailure(ctx, batonv1.ConditionDegraded, "WorkloadNotFound", err)
+	}
+
+	r.observeWorkload(workload)
+
+	// a surge left behind by an interrupted migration is rolled back before anything else
+	if _, isSurged := workload.Annotations[k8s.SurgeReplicasAnnotation]; isSurged {
+		if r.isDryRun() {
+			r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "DryRun", "would restore the replicas of %s surged by an interrupted migration", workload)
+			return r.recordUnbalanced(ctx, "SurgeLeft", fmt.Sprintf("replicas of %s are left surged by an interrupted migration", workload))
+		}
+		err = k8s.RestoreSurgedWorkload(ctx, r.client, r.scales, workload)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to restore replicas of %s", workload))
+			return r.recordFailure(ctx, batonv1.ConditionDegraded, "RestoreReplicasFailed", err)
+		}
+		return r.recordUnbalanced(ctx, "ReplicasRestored", fmt.Sprintf("restored the replicas of %s surged by an interrupted migration, strategies are run next time", workload))
 	}
 
-	err = r.migrateLessPodFromOther(r.baton.Spec.Strategies, deployment)
+	if r.baton.Spec.MigrationMode == batonv1.MigrationModeSurgeFirst && workload.IsStatefulSet() {
+		err = errors.New("SurgeFirst migration mode is not supported for StatefulSets")
+		return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "UnsupportedMigrationMode", err)
+	}
+
+	strategies, err := batonv1.ResolveStrategies(r.baton.Spec.Strategies, workload.Replicas)
 	if err != nil {
-		r.logger.Error(err, "failed to migrate less Pod from other Node")
+		return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "InvalidKeepPods", err)
 	}
-	return nil
-}
 
-func (r *BatonStrategiesyRunner) migrateSuplusPodToOther(
-	strategies []batonv1.Strategy,
-	deployment appsv1.Deployment,
-) error {
-	for _, strategy := range strategies {
-		pods, err := strategy.GetPodsScheduledNodes(r.client, deployment)
+	if r.baton.Spec.SteeringMethod == batonv1.SteeringMethodTaint {
+		err = r.validateSteeringTaint(ctx, workload)
 		if err != nil {
-			r.logger.Error(err, "failed to list Pods")
-			continue
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "ValidationFailed", "%v", err)
+			return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "SteeringTaintTolerated", err)
+		}
+	}
+	if r.baton.Spec.SteeringMethod == batonv1.SteeringMethodNodeAffinity {
+		err = r.validateSteeringLabel(ctx, workload)
+		if err != nil {
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "ValidationFailed", "%v", err)
+			return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "SteeringLabelMissing", err)
 		}
+	}
 
-		if !strategy.IsSuplus(pods) {
-			continue
+	// the terminating nodes are evacuated first, even outside of the schedule since they are going away anyway,
+	// and before the strategies are validated, since pods pending during the churn of the nodes would block it
+	var evacuationErr error
+	migrations := r.planEvacuationMigrations(ctx, strategies, workload)
+	if !r.isDryRun() {
+		evacuationErr = r.executeMigrations(ctx, workload, migrations)
+		if evacuationErr != nil && !isInterrupted(evacuationErr) {
+			r.logger.Error(evacuationErr, "failed to evacuate Pod from terminating Node")
 		}
+	}
 
-		r.logger.Info(fmt.Sprintf("migrate suplus group (%v) to other", strategy.NodeMatchLabels))
-		cordonedNodes, err := strategy.GetMatchNodes(r.client)
-		if err != nil {
-			r.logger.Error(err, "failed to list Nodes")
-			continue
+	err = batonv1.ValidateStrategies(ctx, r.client, workload, strategies)
+	if err != nil {
+		r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "ValidationFailed", "strategies are invalid for %s: %v", workload, err)
+		return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "InvalidStrategies", err)
+	}
+
+	inSchedule, err := r.isInSchedule()
+	if err != nil {
+		r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "ValidationFailed", "schedule is invalid: %v", err)
+		return r.recordFailure(ctx, batonv1.ConditionValidationFailed, "InvalidSchedule", err)
+	}
+
+	var suplusErr, lessErr, skewErr error
+	if r.isDryRun() || !inSchedule {
+		// the less migrations are planned as if the suplus pods were already evicted
+		migrations = append(migrations, r.planSuplusMigrations(ctx, strategies, workload)...)
+		migrations = append(migrations, r.planLessMigrations(ctx, strategies, workload, getMigratingPods(migrations))...)
+		migrations = append(migrations, r.planSkewMigrations(ctx, strategies, workload)...)
+		if r.isDryRun() {
+			r.recordPlan(migrations)
 		}
+	} else {
+		// the less migrations are planned after the suplus pods moved, since they may have landed on the less strategies
+		suplusMigrations := r.planSuplusMigrations(ctx, strategies, workload)
+		suplusErr = r.executeMigrations(ctx, workload, suplusMigrations)
+		if suplusErr != nil && !isInterrupted(suplusErr) {
+			r.logger.Error(suplusErr, "failed to migrate suplus Pod to other Node")
+		}
+		migrations = append(migrations, suplusMigrations...)
 
-		for i, _ := range cordonedNodes {
-			err := k8s.RunCordonOrUncordon(r.client, &cordonedNodes[i], true)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to cordon Node{Name: %s}", cordonedNodes[i].Name))
-				continue
+		// after an abort the later phases would cordon again the nodes the failure policy just released
+		if !errors.Is(suplusErr, errMigrationAborted) && r.checkMigrationsAllowed() == nil {
+			lessMigrations := r.planLessMigrations(ctx, strategies, workload, nil)
+			lessErr = r.executeMigrations(ctx, workload, lessMigrations)
+			if lessErr != nil && !isInterrupted(lessErr) {
+				r.logger.Error(lessErr, "failed to migrate less Pod from other Node")
 			}
+			migrations = append(migrations, lessMigrations...)
 		}
 
-		for _, deletedPod := range pods[strategy.KeepPods:] {
-			observedPods, err := k8s.ListPodMatchLabels(
-				r.client,
-				deployment.ObjectMeta.Namespace,
-				deployment.Spec.Template.ObjectMeta.Labels,
-			)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to list Pods{Namespace: %s, MatchLabels: %v}",
-					deployment.ObjectMeta.Namespace,
-					deployment.Spec.Template.ObjectMeta.Labels,
-				))
+		// the domains are balanced once every strategy keeps its pods
+		if !errors.Is(suplusErr, errMigrationAborted) && !errors.Is(lessErr, errMigrationAborted) &&
+			r.checkMigrationsAllowed() == nil {
+			skewMigrations := r.planSkewMigrations(ctx, strategies, workload)
+			skewErr = r.executeMigrations(ctx, workload, skewMigrations)
+			if skewErr != nil && !isInterrupted(skewErr) {
+				r.logger.Error(skewErr, "failed to spread Pod across domains")
 			}
+			migrations = append(migrations, skewMigrations...)
+		}
+	}
 
-			hash := deletedPod.ObjectMeta.GetLabels()["pod-template-hash"]
-			err = k8s.DeletePod(r.client, deletedPod)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to delete Pod{Name: %s}", deletedPod.ObjectMeta.Name))
-				continue
-			}
+	strategyStatuses, err := r.getStrategyStatuses(ctx, strategies, workload)
+	if err != nil {
+		r.logger.Error(err, "failed to observe strategies")
+	}
+	// the window may have closed during the migrations
+	inSchedule, _ = r.isInSchedule()
 
-			err = r.monitorNewPodsUntilReady(deployment, &hash, observedPods)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to monitor new pod"))
-				continue
+	return r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.LastError = ""
+		for _, err := range []error{evacuationErr, suplusErr, lessErr, skewErr} {
+			// being suspended or leaving the schedule in the middle of the run is not a failure
+			if err != nil && !isInterrupted(err) {
+				status.LastError = err.Error()
 			}
 		}
+		if strategyStatuses != nil {
+			status.Strategies = strategyStatuses
+		}
+		status.Plan = toMigrationPlans(migrations)
+		if r.baton.Spec.Schedule == nil {
+			batonv1.RemoveCondition(&status.Conditions, batonv1.ConditionInWindow)
+		} else if inSchedule {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionInWindow, metav1.ConditionTrue, "InWindow", "migrations are allowed by the schedule")
+		} else {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionInWindow, metav1.ConditionFalse, "OutsideWindow", "migrations are deferred to the next window of the schedule")
+		}
 
-		for _, cordonedNode := range cordonedNodes {
-			var uncordonedNode corev1.Node
-			uncordonedNode, err = k8s.GetNode(r.client, cordonedNode.Name)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to get node {Name: %s}", cordonedNode.Name))
-				continue
-			}
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionProgressing, metav1.ConditionFalse, "Idle", "waiting for the next run")
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionValidationFailed, metav1.ConditionFalse, "Valid", "")
+		if status.LastError != "" {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionDegraded, metav1.ConditionTrue, "MigrationFailed", status.LastError)
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionReady, metav1.ConditionFalse, "MigrationFailed", status.LastError)
+			return
+		}
 
-			err = k8s.RunCordonOrUncordon(r.client, &uncordonedNode, false)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to uncordon Node{Name: %s}", cordonedNode.ObjectMeta.Name))
-				continue
+		status.LastSuccessfulRunAt = time.Now().Format(time.RFC3339)
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionDegraded, metav1.ConditionFalse, "Migrated", "")
+		if isBalanced(status.Strategies) {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionReady, metav1.ConditionTrue, "Balanced", "every strategy keeps its pods")
+		} else {
+			batonv1.SetCondition(&status.Conditions, batonv1.ConditionReady, metav1.ConditionFalse, "Unbalanced", "some strategies do not keep their pods yet")
+		}
+	})
+}
+
+// validateSteeringTaint makes sure no pod of the workload tolerates the steering taint, which would not steer them otherwise
+func (r *BatonStrategiesyRunner) validateSteeringTaint(ctx context.Context, workload k8s.Workload) error {
+	pods, err := k8s.ListPodMatchSelector(ctx, r.client, workload.Namespace, workload.Selector)
+	if err != nil {
+		return err
+	}
+
+	taint := steeringTaint(*r.baton)
+	for _, pod := range pods {
+		for _, toleration := range pod.Spec.Tolerations {
+			if toleration.ToleratesTaint(&taint) {
+				return fmt.Errorf("Pod{Name: %s} of %s tolerates the steering taint %s", pod.ObjectMeta.Name, workload, taint.Key)
 			}
 		}
 	}
 	return nil
 }
 
-func (r *BatonStrategiesyRunner) migrateLessPodFromOther(
-	strategies []batonv1.Strategy,
-	deployment appsv1.Deployment,
+// validateSteeringLabel checks that the pods of the workload opt in to the pod webhook,
+// which only receives the pods carrying the steering label
+func (r *BatonStrategiesyRunner) validateSteeringLabel(ctx context.Context, workload k8s.Workload) error {
+	pods, err := k8s.ListPodMatchSelector(ctx, r.client, workload.Namespace, workload.Selector)
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		if pod.ObjectMeta.Labels[SteeringLabel] != SteeringLabelEnabled {
+			return fmt.Errorf("Pod{Name: %s} of %s is not labeled %s=%s for the pod webhook", pod.ObjectMeta.Name, workload, SteeringLabel, SteeringLabelEnabled)
+		}
+	}
+	return nil
+}
+
+// recordFailure marks the run as failed with the given condition and returns the cause
+func (r *BatonStrategiesyRunner) recordFailure(
+	ctx context.Context,
+	conditionType batonv1.BatonConditionType,
+	reason string,
+	cause error,
 ) error {
+	if conditionType == batonv1.ConditionValidationFailed {
+		validationFailuresTotal.WithLabelValues(r.baton.ObjectMeta.Namespace, r.baton.ObjectMeta.Name).Inc()
+	}
+	err := r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.LastError = cause.Error()
+		batonv1.SetCondition(&status.Conditions, conditionType, metav1.ConditionTrue, reason, cause.Error())
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionReady, metav1.ConditionFalse, reason, cause.Error())
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionProgressing, metav1.ConditionFalse, reason, cause.Error())
+	})
+	if err != nil {
+		r.logger.Error(err, "failed to update status")
+	}
+	return cause
+}
+
+// recordUnbalanced ends a run which did not get to the strategies without a failure
+func (r *BatonStrategiesyRunner) recordUnbalanced(ctx context.Context, reason string, message string) error {
+	return r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.LastError = ""
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionProgressing, metav1.ConditionFalse, "Idle", "waiting for the next run")
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionDegraded, metav1.ConditionFalse, reason, message)
+		batonv1.SetCondition(&status.Conditions, batonv1.ConditionReady, metav1.ConditionFalse, reason, message)
+	})
+}
+
+func (r *BatonStrategiesyRunner) getStrategyStatuses(
+	ctx context.Context,
+	strategies []batonv1.Strategy,
+	workload k8s.Workload,
+) ([]batonv1.StrategyStatus, error) {
+	remainingPods := workload.Replicas - int32(batonv1.GetTotalKeepPods(strategies))
+
+	strategyStatuses := []batonv1.StrategyStatus{}
 	for _, strategy := range strategies {
-		pods, err := strategy.GetPodsScheduledNodes(r.client, deployment)
+		nodes, err := strategy.GetMatchNodes(ctx, r.client)
 		if err != nil {
-			r.logger.Error(err, "failed to list Pods")
-			continue
+			return nil, err
+		}
+
+		pods, err := strategy.GetPodsScheduledNodes(ctx, r.client, workload)
+		if err != nil {
+			return nil, err
+		}
+
+		// strategies without keepPods share the pods the others do not keep
+		desiredPods := strategy.GetKeepPods()
+		if strategy.IsRest() {
+			desiredPods = remainingPods
 		}
+		metadata := r.baton.ObjectMeta
+		strategyDesiredPods.WithLabelValues(metadata.Namespace, metadata.Name, strategy.String()).Set(float64(desiredPods))
+		strategyCurrentPods.WithLabelValues(metadata.Namespace, metadata.Name, strategy.String()).Set(float64(len(pods)))
 
-		if !strategy.IsLess(pods) {
+		strategyStatus := batonv1.StrategyStatus{
+			NodeMatchLabels: strategy.NodeMatchLabels,
+			NodeSelector:    strategy.String(),
+			MatchedNodes:    int32(len(nodes)),
+			CurrentPods:     int32(len(pods)),
+			KeepPods:        strategy.GetKeepPods(),
+		}
+		if strategy.Weight != nil {
+			strategyStatus.Weight = *strategy.Weight
+		}
+		strategyStatus.Rest = strategy.IsRest()
+		if strategy.TopologyKey != "" {
+			strategyStatus.PodsPerDomain = strategy.GetPodsPerDomain(nodes, pods)
+		}
+		strategyStatuses = append(strategyStatuses, strategyStatus)
+	}
+	return strategyStatuses, nil
+}
+
+func isBalanced(strategyStatuses []batonv1.StrategyStatus) bool {
+	for _, strategyStatus := range strategyStatuses {
+		if !strategyStatus.Rest && strategyStatus.CurrentPods != strategyStatus.KeepPods {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *BatonStrategiesyRunner) updateStatus(ctx context.Context, mutate func(*batonv1.BatonStatus)) error {
+	key := client.ObjectKey{Namespace: r.baton.ObjectMeta.Namespace, Name: r.baton.ObjectMeta.Name}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		baton := batonv1.Baton{}
+		if err := r.client.Get(ctx, key, &baton); err != nil {
+			return err
+		}
+		mutate(&baton.Status)
+		return r.client.Status().Update(ctx, &baton)
+	})
+}
+
+// executeMigrations cordons the nodes of each migration while its pods are migrated,
+// and stops at the first migration blocked by a PodDisruptionBudget
+func (r *BatonStrategiesyRunner) executeMigrations(ctx context.Context, workload k8s.Workload, migrations []migration) error {
+	for _, m := range migrations {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := r.checkMigrationAllowed(m); err != nil {
+			return err
+		}
+		r.logger.Info(fmt.Sprintf("migrate %s group (%s)", m.kind, m.strategy))
+		r.steer(ctx, workload, m)
+		cordonedAt := time.Now()
+
+		err := r.migratePods(ctx, workload, m)
+
+		// the nodes are released even when the runner is stopped in the middle of the migration
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		r.unsteer(cleanupCtx, workload, m)
+		cancel()
+		cordonDurationSeconds.
+			WithLabelValues(r.baton.ObjectMeta.Namespace, r.baton.ObjectMeta.Name, m.strategy.String()).
+			Observe(time.Since(cordonedAt).Seconds())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// steer keeps the pods of the workload from being scheduled to the nodes they are migrated from
+func (r *BatonStrategiesyRunner) steer(ctx context.Context, workload k8s.Workload, m migration) {
+	switch r.baton.Spec.SteeringMethod {
+	case batonv1.SteeringMethodTaint:
+		r.taintNodes(ctx, m.cordonNodes)
+	case batonv1.SteeringMethodNodeAffinity:
+		r.setSteering(ctx, workload, m.targetStrategies)
+	default:
+		r.cordonNodes(ctx, m.cordonNodes)
+	}
+}
+
+// unsteer lets the pods of the workload be scheduled to the nodes again
+func (r *BatonStrategiesyRunner) unsteer(ctx context.Context, workload k8s.Workload, m migration) {
+	switch r.baton.Spec.SteeringMethod {
+	case batonv1.SteeringMethodTaint:
+		r.untaintNodes(ctx, m.cordonNodes)
+	case batonv1.SteeringMethodNodeAffinity:
+		r.clearSteering(ctx)
+	default:
+		r.uncordonNodes(ctx, m.cordonNodes)
+	}
+}
+
+// setSteering publishes the node affinity the pod webhook injects into the new pods of the workload.
+// It waits until the cache serves the steering, since the webhook reads Batons from the cache.
+// The nodes excluded from the target strategies are those excluded when the steering starts.
+func (r *BatonStrategiesyRunner) setSteering(ctx context.Context, workload k8s.Workload, targetStrategies []batonv1.Strategy) {
+	steering := batonv1.Steering{
+		PodSelector: workload.Selector.String(),
+		Namespace:   workload.Namespace,
+		StartedAt:   time.Now().Format(time.RFC3339),
+	}
+	for _, strategy := range targetStrategies {
+		excludedNodes, err := strategy.GetExcludedNodes(ctx, r.client)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to list excluded Nodes{Strategy: %s}", strategy))
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "SteeringFailed", "failed to steer new pods: %v", err)
+			return
+		}
+		steering.NodeSelectorTerms = append(steering.NodeSelectorTerms, strategy.NodeSelectorTerm(excludedNodes))
+	}
+
+	err := r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.Steering = &steering
+	})
+	if err != nil {
+		r.logger.Error(err, "failed to update status")
+		r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "SteeringFailed", "failed to steer new pods: %v", err)
+		return
+	}
+
+	key := client.ObjectKey{Namespace: r.baton.ObjectMeta.Namespace, Name: r.baton.ObjectMeta.Name}
+	err = wait.PollImmediate(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+		baton := batonv1.Baton{}
+		if err := r.client.Get(ctx, key, &baton); err != nil {
+			return false, err
+		}
+		return baton.Status.Steering != nil && baton.Status.Steering.StartedAt == steering.StartedAt, nil
+	})
+	if err != nil {
+		r.logger.Error(err, "failed to observe steering in the cache")
+	}
+	r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Steering", "steering new pods of %s to %d strategies", workload, len(targetStrategies))
+}
+
+// clearSteering stops injecting the node affinity into the new pods
+func (r *BatonStrategiesyRunner) clearSteering(ctx context.Context) {
+	err := r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.Steering = nil
+	})
+	if err != nil {
+		r.logger.Error(err, "failed to update status")
+	}
+}
+
+// taintNodes applies the steering taint of the Baton, which only the pods of its workload do not tolerate
+func (r *BatonStrategiesyRunner) taintNodes(ctx context.Context, nodes []corev1.Node) {
+	taint := steeringTaint(*r.baton)
+	for _, node := range nodes {
+		err := k8s.TaintNode(ctx, r.client, node.ObjectMeta.Name, taint)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to taint Node{Name: %s}", node.ObjectMeta.Name))
+			r.recorder.Eventf(&node, corev1.EventTypeWarning, "TaintFailed", "Baton %s failed to taint node: %v", cordonOwner(*r.baton), err)
 			continue
 		}
-		r.logger.Info(fmt.Sprintf("migrate less group (%v) from other", strategy.NodeMatchLabels))
+		r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Tainted", "tainted Node %s with %s", node.ObjectMeta.Name, taint.Key)
+	}
+}
 
-		suplusStrategies := batonv1.FilterStrategies(strategies, func(s batonv1.Strategy) bool {
-			if s.KeepPods == 0 {
-				return true
-			}
-			isSuplus, _ := s.IsSuplusWithPodsScheduledNodes(r.client, deployment)
-			return isSuplus
-		})
+// untaintNodes removes the steering taint of the Baton
+func (r *BatonStrategiesyRunner) untaintNodes(ctx context.Context, nodes []corev1.Node) {
+	taint := steeringTaint(*r.baton)
+	for _, node := range nodes {
+		isUntainted, err := k8s.UntaintNode(ctx, r.client, node.ObjectMeta.Name, taint.Key)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to untaint Node{Name: %s}", node.ObjectMeta.Name))
+			r.recorder.Eventf(&node, corev1.EventTypeWarning, "UntaintFailed", "Baton %s failed to untaint node: %v", cordonOwner(*r.baton), err)
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "UntaintFailed", "failed to untaint Node %s: %v", node.ObjectMeta.Name, err)
+			continue
+		}
+		if isUntainted {
+			r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Untainted", "removed %s from Node %s", taint.Key, node.ObjectMeta.Name)
+		}
+	}
+}
 
-		deleatablePod, err := batonv1.GetStrategiesPodsScheduledNodes(r.client, deployment, suplusStrategies)
+// cordonNodes cordons the nodes on behalf of the Baton, sharing the cordons of other Batons.
+// Nodes cordoned by someone else are left as they are.
+func (r *BatonStrategiesyRunner) cordonNodes(ctx context.Context, nodes []corev1.Node) {
+	owner := cordonOwner(*r.baton)
+	for i := range nodes {
+		unlock := r.nodeLocker.lock(nodes[i].ObjectMeta.Name)
+		isOwned, err := k8s.CordonNode(ctx, r.client, nodes[i].ObjectMeta.Name, owner)
+		unlock()
 		if err != nil {
-			r.logger.Error(err, "failed to list Pods")
+			r.logger.Error(err, fmt.Sprintf("failed to cordon Node{Name: %s}", nodes[i].ObjectMeta.Name))
+			r.recorder.Eventf(&nodes[i], corev1.EventTypeWarning, "CordonFailed", "Baton %s failed to cordon node: %v", owner, err)
 			continue
 		}
+		if !isOwned {
+			r.logger.Info(fmt.Sprintf("Node{Name: %s} is already cordoned by someone else", nodes[i].ObjectMeta.Name))
+			continue
+		}
+		r.recorder.Eventf(&nodes[i], corev1.EventTypeNormal, "Cordoned", "cordoned by Baton %s", owner)
+		r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Cordoned", "cordoned Node %s", nodes[i].ObjectMeta.Name)
+	}
+}
 
-		cordonedNodes, err := batonv1.GetStrategiesMatchNodes(r.client, suplusStrategies)
+// uncordonNodes releases the cordons the Baton holds. A node stays cordoned while other Batons hold it.
+func (r *BatonStrategiesyRunner) uncordonNodes(ctx context.Context, nodes []corev1.Node) {
+	owner := cordonOwner(*r.baton)
+	for i := range nodes {
+		unlock := r.nodeLocker.lock(nodes[i].ObjectMeta.Name)
+		isUncordoned, err := k8s.UncordonNode(ctx, r.client, nodes[i].ObjectMeta.Name, owner)
+		unlock()
 		if err != nil {
-			r.logger.Error(err, "failed to list Nodes")
+			r.logger.Error(err, fmt.Sprintf("failed to uncordon Node{Name: %s}", nodes[i].ObjectMeta.Name))
+			r.recorder.Eventf(&nodes[i], corev1.EventTypeWarning, "UncordonFailed", "Baton %s failed to uncordon node: %v", owner, err)
+			r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "UncordonFailed", "failed to uncordon Node %s: %v", nodes[i].ObjectMeta.Name, err)
+			continue
+		}
+		if !isUncordoned {
 			continue
 		}
+		r.recorder.Eventf(&nodes[i], corev1.EventTypeNormal, "Uncordoned", "uncordoned by Baton %s", owner)
+		r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Uncordoned", "uncordoned Node %s", nodes[i].ObjectMeta.Name)
+	}
+}
 
-		for i, _ := range cordonedNodes {
-			err := k8s.RunCordonOrUncordon(r.client, &cordonedNodes[i], true)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to cordon Node{Name: %s}", cordonedNodes[i].ObjectMeta.Name))
-				continue
-			}
+// migratePods migrates the pods in batches of maxConcurrentMigrations, and stops after a batch with an eviction
+// blocked by a PodDisruptionBudget. A failed migration stops the remaining ones unless the failure policy is Continue.
+func (r *BatonStrategiesyRunner) migratePods(ctx context.Context, workload k8s.Workload, m migration) error {
+	migrated := func(result string) {
+		podsMigratedTotal.
+			WithLabelValues(r.baton.ObjectMeta.Namespace, r.baton.ObjectMeta.Name, m.strategy.String(), result).
+			Inc()
+	}
+
+	batchSize := r.maxConcurrentMigrations(workload)
+	for start := 0; start < len(m.pods); start += batchSize {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// the batch being migrated is finished before pausing, and the nodes are released by the caller
+		if err := r.checkMigrationAllowed(m); err != nil {
+			r.logger.Info(fmt.Sprintf("stop migrating %s group (%s): %v", m.kind, m.strategy, err))
+			return err
+		}
+		observedPods, err := k8s.ListPodMatchSelector(ctx, r.client, workload.Namespace, workload.Selector)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to list Pods{Namespace: %s, Selector: %s}", workload.Namespace, workload.Selector))
+			return err
 		}
 
-		for _, deletedPod := range deleatablePod[:strategy.KeepPods] {
-			observedPods, err := k8s.ListPodMatchLabels(
-				r.client,
-				deployment.ObjectMeta.Namespace,
-				deployment.Spec.Template.ObjectMeta.Labels,
-			)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to list Pods{Namespace: %s, MatchLabels: %v}",
-					deployment.ObjectMeta.Namespace,
-					deployment.Spec.Template.ObjectMeta.Labels,
-				))
-			}
+		end := start + batchSize
+		if end > len(m.pods) {
+			end = len(m.pods)
+		}
+		batch := m.pods[start:end]
+		errs := r.migrateBatch(ctx, workload, batch, observedPods, m.targetNodes)
 
-			hash := deletedPod.ObjectMeta.GetLabels()["pod-template-hash"]
-			err = k8s.DeletePod(r.client, deletedPod)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to delete Pod{Name: %s}", deletedPod.ObjectMeta.Name))
+		var blockedErr, failedErr error
+		var failedPod corev1.Pod
+		pending := 0
+		for i, pod := range batch {
+			err := errs[i]
+			switch {
+			case errors.Is(err, k8s.ErrEvictionBlocked):
+				migrated(migrationResultBlocked)
+				r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "EvictionBlocked", "eviction of Pod %s is blocked by PodDisruptionBudget", pod.ObjectMeta.Name)
+				r.recorder.Eventf(workload.Object, corev1.EventTypeWarning, "EvictionBlocked", "eviction of Pod %s is blocked by PodDisruptionBudget", pod.ObjectMeta.Name)
+				if blockedErr == nil {
+					blockedErr = fmt.Errorf("blocked by PDB: failed to evict Pod{Name: %s}: %w", pod.ObjectMeta.Name, err)
+				}
+				continue
+			case errors.Is(err, errMonitorTimeout):
+				migrated(migrationResultTimeout)
+				r.logger.Error(err, fmt.Sprintf("failed to migrate Pod{Name: %s}", pod.ObjectMeta.Name))
+				r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "MonitorTimeout", "replacement of Pod %s was not ready in %ds", pod.ObjectMeta.Name, r.baton.Spec.MonitorTimeoutSec)
+				r.recorder.Eventf(workload.Object, corev1.EventTypeWarning, "MonitorTimeout", "replacement of Pod %s was not ready in %ds", pod.ObjectMeta.Name, r.baton.Spec.MonitorTimeoutSec)
+			case err != nil:
+				migrated(migrationResultFailed)
+				r.logger.Error(err, fmt.Sprintf("failed to migrate Pod{Name: %s}", pod.ObjectMeta.Name))
+				r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "MigrationFailed", "failed to migrate Pod %s: %v", pod.ObjectMeta.Name, err)
+			default:
+				migrated(migrationResultSucceeded)
+				r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "Migrated", "migrated Pod %s", pod.ObjectMeta.Name)
 				continue
 			}
 
-			err = r.monitorNewPodsUntilReady(deployment, &hash, observedPods)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to monitor new pod"))
-				continue
+			if errors.Is(err, errPodUnschedulable) || errors.Is(err, errMonitorTimeout) {
+				pending++
+			}
+			if failedErr == nil {
+				failedErr, failedPod = err, pod
 			}
 		}
 
-		for _, cordonedNode := range cordonedNodes {
-			var uncordonedNode corev1.Node
-			uncordonedNode, err = k8s.GetNode(r.client, cordonedNode.Name)
+		if blockedErr != nil {
+			return blockedErr
+		}
+		// an evacuation is never aborted nor rolled back, since the pods left behind are lost with their nodes anyway
+		if failedErr != nil && m.kind != migrationKindEvacuation &&
+			r.baton.Spec.FailurePolicy != batonv1.FailurePolicyContinue && r.baton.Spec.FailurePolicy != "" {
+			return r.abortMigration(ctx, workload, m, failedPod, observedPods, pending, failedErr)
+		}
+	}
+	return nil
+}
+
+// maxConcurrentMigrations resolves spec.maxConcurrentMigrations against the replicas of the workload, and is at least 1
+func (r *BatonStrategiesyRunner) maxConcurrentMigrations(workload k8s.Workload) int {
+	if r.baton.Spec.MaxConcurrentMigrations == nil {
+		return 1
+	}
+	maxConcurrentMigrations, err := intstr.GetValueFromIntOrPercent(r.baton.Spec.MaxConcurrentMigrations, int(workload.Replicas), false)
+	if err != nil || maxConcurrentMigrations < 1 {
+		return 1
+	}
+	return maxConcurrentMigrations
+}
+
+// newMigrationLimiter returns the limiter of spec.migrationsPerMinute, or nil when it is not limited
+func newMigrationLimiter(baton batonv1.Baton) flowcontrol.RateLimiter {
+	if baton.Spec.MigrationsPerMinute <= 0 {
+		return nil
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(float32(baton.Spec.MigrationsPerMinute)/60, 1)
+}
+
+// abortMigration stops the remaining migrations after the pod failed to migrate and records it in the status.
+// With the Rollback failure policy the source nodes are uncordoned right away,
+// so a replacement which did not fit elsewhere can be scheduled back where the pod came from.
+func (r *BatonStrategiesyRunner) abortMigration(
+	ctx context.Context,
+	workload k8s.Workload,
+	m migration,
+	pod corev1.Pod,
+	observedPods []corev1.Pod,
+	pending int,
+	cause error,
+) error {
+	abortedMigration := batonv1.AbortedMigration{
+		Strategy:  m.strategy.String(),
+		Type:      m.kind,
+		Pod:       pod.ObjectMeta.Name,
+		Reason:    cause.Error(),
+		AbortedAt: time.Now().Format(time.RFC3339),
+	}
+	r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "MigrationAborted", "aborted the migration of %s group (%s) at Pod %s: %v", m.kind, m.strategy, pod.ObjectMeta.Name, cause)
+
+	if r.baton.Spec.FailurePolicy == batonv1.FailurePolicyRollback {
+		r.unsteer(ctx, workload, m)
+		abortedMigration.RolledBack = true
+
+		// only evicted pods whose replacements are still pending have to be rescheduled, surged pods are already scaled in
+		if pending > 0 && r.baton.Spec.MigrationMode != batonv1.MigrationModeSurgeFirst {
+			if r.baton.Spec.SteeringMethod == batonv1.SteeringMethodNodeAffinity {
+				r.deleteSteeredPendingPods(ctx, workload, observedPods)
+			}
+			err := r.monitorNewPodsUntilReady(ctx, workload, k8s.PodRevision(pod), observedPods, nil, pending)
 			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to get node {Name: %s}", cordonedNode.Name))
-				continue
+				abortedMigration.RolledBack = false
+				r.logger.Error(err, fmt.Sprintf("failed to roll back the migration of Pod{Name: %s}", pod.ObjectMeta.Name))
+				r.recorder.Eventf(r.baton, corev1.EventTypeWarning, "RollbackFailed", "replacement of Pod %s was not ready after uncordoning the source nodes: %v", pod.ObjectMeta.Name, err)
 			}
+		}
+		if abortedMigration.RolledBack {
+			r.recorder.Eventf(r.baton, corev1.EventTypeNormal, "RolledBack", "uncordoned the source nodes of Pod %s", pod.ObjectMeta.Name)
+		}
+	}
 
-			err = k8s.RunCordonOrUncordon(r.client, &uncordonedNode, false)
-			if err != nil {
-				r.logger.Error(err, fmt.Sprintf("failed to uncordon Node{Name: %s}", cordonedNode.ObjectMeta.Name))
+	err := r.updateStatus(ctx, func(status *batonv1.BatonStatus) {
+		status.LastAbortedMigration = &abortedMigration
+	})
+	if err != nil {
+		r.logger.Error(err, "failed to update status")
+	}
+	return fmt.Errorf("%w of Pod{Name: %s}: %v", errMigrationAborted, pod.ObjectMeta.Name, cause)
+}
+
+// deleteSteeredPendingPods deletes the new pods which are still pending with the node affinity injected by the Baton.
+// The affinity of a pod can not be removed, so they are recreated without it once the steering is cleared.
+func (r *BatonStrategiesyRunner) deleteSteeredPendingPods(ctx context.Context, workload k8s.Workload, observedPods []corev1.Pod) {
+	currentPods, err := k8s.ListPodMatchSelector(ctx, r.client, workload.Namespace, workload.Selector)
+	if err != nil {
+		r.logger.Error(err,
+			fmt.Sprintf("failed to list Pods{Namespace: %s, Selector: %s}",
+				workload.Namespace, workload.Selector),
+		)
+		return
+	}
+
+	steeredPods := k8s.FilterPods(getNewPods(observedPods, currentPods), func(p corev1.Pod) bool {
+		return p.ObjectMeta.DeletionTimestamp == nil &&
+			p.Spec.NodeName == "" &&
+			p.ObjectMeta.Annotations[SteeredByAnnotation] == cordonOwner(*r.baton)
+	})
+	for i := range steeredPods {
+		err := client.IgnoreNotFound(r.client.Delete(ctx, &steeredPods[i]))
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to delete Pod{Name: %s}", steeredPods[i].ObjectMeta.Name))
+		}
+	}
+}
+
+// migrateBatch moves the pods off their nodes at once and waits until as many replacements are ready on
+// one of the target nodes. It returns the error of each pod in the order of the pods.
+func (r *BatonStrategiesyRunner) migrateBatch(
+	ctx context.Context,
+	workload k8s.Workload,
+	pods []corev1.Pod,
+	observedPods []corev1.Pod,
+	targetNodes []corev1.Node,
+) []error {
+	revision := getBatchRevision(pods)
+
+	if r.baton.Spec.MigrationMode == batonv1.MigrationModeSurgeFirst {
+		return r.surgeAndMigratePods(ctx, workload, pods, revision, observedPods, targetNodes)
+	}
+
+	errs := r.evictPods(ctx, workload, pods)
+	evicted := 0
+	for _, err := range errs {
+		if err == nil {
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		return errs
+	}
+
+	err := r.monitorNewPodsUntilReady(ctx, workload, revision, observedPods, targetNodes, evicted)
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// surgeAndMigratePods scales the workload up by the number of pods and evicts them only after the
+// additional pods are ready, so the workload never runs below its desired replicas.
+// Restoring the replicas right after the evictions lets the workload controller discard
+// the pods it creates to replace the evicted ones.
+func (r *BatonStrategiesyRunner) surgeAndMigratePods(
+	ctx context.Context,
+	workload k8s.Workload,
+	pods []corev1.Pod,
+	revision string,
+	observedPods []corev1.Pod,
+	targetNodes []corev1.Node,
+) []error {
+	errs := make([]error, len(pods))
+	setErr := func(err error) []error {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	err := k8s.SurgeWorkload(ctx, r.client, r.scales, &workload, int32(len(pods)))
+	if err != nil {
+		return setErr(err)
+	}
+	defer func() {
+		// the replicas are restored even when the runner is stopped
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		err := k8s.RestoreSurgedWorkload(cleanupCtx, r.client, r.scales, workload)
+		if err != nil {
+			r.logger.Error(err, fmt.Sprintf("failed to restore replicas of %s", workload))
+		}
+	}()
+
+	err = r.monitorNewPodsUntilReady(ctx, workload, revision, observedPods, targetNodes, len(pods))
+	if err != nil {
+		return setErr(err)
+	}
+	return r.evictPods(ctx, workload, pods)
+}
+
+// evictPods evicts the pods in parallel, each once the migration rate limit allows it
+func (r *BatonStrategiesyRunner) evictPods(ctx context.Context, workload k8s.Workload, pods []corev1.Pod) []error {
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		if r.migrationLimiter != nil {
+			if err := r.migrationLimiter.Wait(ctx); err != nil {
+				errs[i] = err
 				continue
 			}
 		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = k8s.EvictPod(ctx, r.clientset, pods[i])
+			if errs[i] == nil {
+				r.recorder.Eventf(workload.Object, corev1.EventTypeNormal, "PodEvicted", "Baton %s evicted Pod %s", r.baton.ObjectMeta.Name, pods[i].ObjectMeta.Name)
+			}
+		}(i)
 	}
-	return nil
+	wg.Wait()
+	return errs
+}
+
+// getBatchRevision returns the revision shared by the pods, or "" to accept replacements of any revision
+func getBatchRevision(pods []corev1.Pod) string {
+	revision := k8s.PodRevision(pods[0])
+	for _, pod := range pods[1:] {
+		if k8s.PodRevision(pod) != revision {
+			return ""
+		}
+	}
+	return revision
 }
 
+// monitorNewPodsUntilReady waits until count new pods of the revision have been ready for minReadySeconds.
+// It fails as soon as a new pod can not become ready or is ready outside of the target nodes.
 func (r *BatonStrategiesyRunner) monitorNewPodsUntilReady(
-	deployment appsv1.Deployment,
-	podTemplateHash *string,
+	ctx context.Context,
+	workload k8s.Workload,
+	revision string,
 	observedPods []corev1.Pod,
+	targetNodes []corev1.Node,
+	count int,
 ) error {
-	namespace := deployment.ObjectMeta.Namespace
-	labels := deployment.Spec.Template.ObjectMeta.Labels
+	startedAt := time.Now()
+	defer func() {
+		monitorDurationSeconds.
+			WithLabelValues(r.baton.ObjectMeta.Namespace, r.baton.ObjectMeta.Name).
+			Observe(time.Since(startedAt).Seconds())
+	}()
+
 	timeout := time.After(time.Duration(r.baton.Spec.MonitorTimeoutSec) * time.Second)
-	tick := time.Tick(15 * time.Second)
-Monitor:
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-timeout:
-			return errors.New("time out to monitor new pod")
-		case <-tick:
-			currentPods, err := k8s.ListPodMatchLabels(r.client, namespace, labels)
-			if err != nil {
-				r.logger.Error(err,
-					fmt.Sprintf("failed to list Pods{Namespace: %s, MatchLabels: %v}",
-						namespace, labels),
-				)
-				return err
-			}
+			monitorTimeoutsTotal.WithLabelValues(r.baton.ObjectMeta.Namespace, r.baton.ObjectMeta.Name).Inc()
+			return errMonitorTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 
-			filterdCurrentPods := k8s.FilterPods(currentPods, func(p corev1.Pod) bool {
-				if (podTemplateHash == nil) || (*podTemplateHash == p.ObjectMeta.GetLabels()["pod-template-hash"]) {
-					return true
-				}
+		currentPods, err := k8s.ListPodMatchSelector(ctx, r.client, workload.Namespace, workload.Selector)
+		if err != nil {
+			r.logger.Error(err,
+				fmt.Sprintf("failed to list Pods{Namespace: %s, Selector: %s}",
+					workload.Namespace, workload.Selector),
+			)
+			return err
+		}
+
+		filterdCurrentPods := k8s.FilterPods(currentPods, func(p corev1.Pod) bool {
+			if p.ObjectMeta.DeletionTimestamp != nil {
 				return false
-			})
+			}
+			return revision == "" || revision == k8s.PodRevision(p)
+		})
 
-			newPods := getNewPods(observedPods, filterdCurrentPods)
-			if len(newPods) == 0 {
-				continue Monitor
+		newPods := getNewPods(observedPods, filterdCurrentPods)
+		for _, pod := range newPods {
+			switch reason := k8s.GetPodFailureReason(pod); reason {
+			case "":
+			case corev1.PodReasonUnschedulable:
+				return fmt.Errorf("%w: Pod{Name: %s}", errPodUnschedulable, pod.ObjectMeta.Name)
+			default:
+				return fmt.Errorf("%w: Pod{Name: %s} is %s", errPodFailed, pod.ObjectMeta.Name, reason)
 			}
+		}
 
-			for _, pod := range newPods {
-				phase := pod.Status.Phase
-				nodeName := pod.Spec.NodeName
-				if nodeName == "" || phase == "Unknow" {
-					continue Monitor
-				} else if phase == "Failed" {
-					return errors.New("failed to launch pod")
-				}
+		now := time.Now()
+		available := 0
+		for _, pod := range newPods {
+			if !k8s.IsPodAvailable(pod, workload.MinReadySeconds, now) {
+				continue
+			}
+			if len(targetNodes) != 0 && !containsNode(targetNodes, pod.Spec.NodeName) {
+				return fmt.Errorf("%w: Pod{Name: %s} is on Node{Name: %s}", errPodMisplaced, pod.ObjectMeta.Name, pod.Spec.NodeName)
 			}
+			available++
+		}
+		if available >= count {
 			return nil
 		}
 	}
 }
 
+func containsNode(nodes []corev1.Node, nodeName string) bool {
+	for _, node := range nodes {
+		if node.ObjectMeta.Name == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// getNewPods compares UIDs rather than names, because a StatefulSet recreates a pod under the same name
 func getNewPods(observedPods []corev1.Pod, currentPods []corev1.Pod) []corev1.Pod {
 	includePods := func(pod corev1.Pod, pods []corev1.Pod) bool {
 		for _, p := range pods {
-			if p.Name == pod.Name {
+			if p.ObjectMeta.UID == pod.ObjectMeta.UID {
 				return true
 			}
 		}
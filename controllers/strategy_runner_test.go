@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	scalefake "k8s.io/client-go/scale/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	batonv1 "trsnium.com/baton/api/v1"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+// testCluster is a fake cluster with a Deployment of two pods on the node of pool a,
+// and a Baton keeping one of them there and moving the other to the node of pool b
+type testCluster struct {
+	client    client.Client
+	clientset *kubernetesfake.Clientset
+	scales    *scalefake.FakeScaleClient
+	mapper    meta.RESTMapper
+	baton     batonv1.Baton
+
+	mutex    sync.Mutex
+	replicas int32
+	evicted  chan string
+}
+
+func newTestCluster(t *testing.T, migrationMode batonv1.MigrationMode) *testCluster {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := batonv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	objects := []runtime.Object{deployment, newTestNode("node-a", "a"), newTestNode("node-b", "b")}
+	for _, name := range []string{"web-1", "web-2"} {
+		objects = append(objects, newTestPod(name, "node-a"))
+	}
+
+	baton := batonv1.Baton{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "baton", Generation: 1},
+		Spec: batonv1.BatonSpec{
+			Deployment: &batonv1.Deployment{Name: "web", NameSpace: "default"},
+			Strategies: []batonv1.Strategy{
+				{NodeMatchLabels: map[string]string{"pool": "a"}, KeepPods: intstr.FromInt(1)},
+				{NodeMatchLabels: map[string]string{"pool": "b"}},
+			},
+			IntervalSec:       3600,
+			MonitorTimeoutSec: 300,
+			MigrationMode:     migrationMode,
+		},
+	}
+	objects = append(objects, baton.DeepCopy())
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+
+	c := &testCluster{
+		client:    fake.NewFakeClientWithScheme(scheme, objects...),
+		clientset: kubernetesfake.NewSimpleClientset(),
+		scales:    &scalefake.FakeScaleClient{},
+		mapper:    mapper,
+		baton:     baton,
+		replicas:  replicas,
+		evicted:   make(chan string, 2),
+	}
+
+	// the evictions are only recorded, so the runner keeps waiting for the replacements
+	c.clientset.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		c.evicted <- action.(clienttesting.CreateAction).GetObject().(metav1.Object).GetName()
+		return true, nil, nil
+	})
+	c.scales.AddReactor("get", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, c.getScale(), nil
+	})
+	c.scales.AddReactor("update", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		s := action.(clienttesting.UpdateAction).GetObject().(*autoscalingv1.Scale)
+		c.mutex.Lock()
+		c.replicas = s.Spec.Replicas
+		c.mutex.Unlock()
+		return true, c.getScale(), nil
+	})
+	return c
+}
+
+func newTestNode(name string, pool string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": pool}},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func newTestPod(name string, nodeName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{"app": "web", "pod-template-hash": "1"},
+		},
+		Spec: corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func (c *testCluster) getScale() *autoscalingv1.Scale {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: c.replicas},
+		Status:     autoscalingv1.ScaleStatus{Replicas: c.replicas, Selector: "app=web"},
+	}
+}
+
+func (c *testCluster) getReplicas() int32 {
+	return c.getScale().Spec.Replicas
+}
+
+func (c *testCluster) newRunner() *BatonStrategiesyRunner {
+	return NewBatonStrategiesyRunner(c.client, c.clientset, c.scales, c.mapper, &record.FakeRecorder{}, c.baton, false, newNodeLocker(), log.NullLogger{}, "default-baton")
+}
+
+func (c *testCluster) newManager() *BatonStrategiesRunnerManager {
+	return NewBatonStrategiesyRunnerManager(c.client, c.clientset, c.scales, c.mapper, &record.FakeRecorder{}, false, log.NullLogger{})
+}
+
+func (c *testCluster) getNode(t *testing.T, name string) corev1.Node {
+	node := corev1.Node{}
+	if err := c.client.Get(context.Background(), client.ObjectKey{Name: name}, &node); err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func (c *testCluster) expectUncordoned(t *testing.T, name string) {
+	node := c.getNode(t, name)
+	if node.Spec.Unschedulable || len(k8s.GetCordonOwners(node)) != 0 {
+		t.Errorf("Node %s is still cordoned by %v", name, k8s.GetCordonOwners(node))
+	}
+}
+
+func (c *testCluster) expectCordoned(t *testing.T, name string) {
+	node := c.getNode(t, name)
+	if !node.Spec.Unschedulable || !contains(k8s.GetCordonOwners(node), "default/baton") {
+		t.Errorf("Node %s is not cordoned by the Baton", name)
+	}
+}
+
+func TestStopInTheMiddleOfMigrationReleasesNodes(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	runner := cluster.newRunner()
+	runner.Run(context.Background())
+
+	select {
+	case name := <-cluster.evicted:
+		if name != "web-2" {
+			t.Errorf("evicted Pod %s, expected web-2 beyond the keepPods of pool a", name)
+		}
+	case <-time.After(10 * time.Second):
+		runner.Stop()
+		runner.Wait()
+		t.Fatal("no Pod was evicted")
+	}
+	cluster.expectCordoned(t, "node-a")
+
+	runner.Stop()
+	runner.Wait()
+	cluster.expectUncordoned(t, "node-a")
+	cluster.expectUncordoned(t, "node-b")
+}
+
+func TestStopInTheMiddleOfSurgeRestoresReplicas(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeSurgeFirst)
+	runner := cluster.newRunner()
+	runner.Run(context.Background())
+
+	err := wait.PollImmediate(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return cluster.getReplicas() == 3, nil
+	})
+	if err != nil {
+		runner.Stop()
+		runner.Wait()
+		t.Fatal("the Deployment was not surged")
+	}
+	cluster.expectCordoned(t, "node-a")
+
+	runner.Stop()
+	runner.Wait()
+	if replicas := cluster.getReplicas(); replicas != 2 {
+		t.Errorf("replicas are %d after the runner stopped, expected 2", replicas)
+	}
+	deployment := appsv1.Deployment{}
+	if err := cluster.client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "web"}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if _, isSurged := deployment.ObjectMeta.Annotations[k8s.SurgeReplicasAnnotation]; isSurged {
+		t.Errorf("%s is left on the Deployment", k8s.SurgeReplicasAnnotation)
+	}
+	cluster.expectUncordoned(t, "node-a")
+}
+
+func TestFailedRunWaitsForInterval(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	// the replacement never shows up, so the migration times out and fails under the Continue policy
+	cluster.baton.Spec.MonitorTimeoutSec = 1
+	runner := cluster.newRunner()
+	runner.Run(context.Background())
+	defer func() {
+		runner.Stop()
+		runner.Wait()
+	}()
+
+	select {
+	case <-cluster.evicted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no Pod was evicted")
+	}
+	// the eviction and the replacement trigger the runner while it is monitoring
+	runner.Trigger()
+
+	select {
+	case name := <-cluster.evicted:
+		t.Errorf("evicted Pod %s again before intervalSec passed", name)
+	case <-time.After(triggerDebounce + 3*time.Second):
+	}
+	cluster.expectUncordoned(t, "node-a")
+}
+
+func TestDeleteSteeredPendingPods(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	observedPods := []corev1.Pod{*newTestPod("web-1", "node-a"), *newTestPod("web-2", "node-a")}
+	observedPods[0].ObjectMeta.UID = "web-1"
+	observedPods[1].ObjectMeta.UID = "web-2"
+
+	steered := map[string]string{SteeredByAnnotation: "default/baton"}
+	pods := []struct {
+		name        string
+		nodeName    string
+		annotations map[string]string
+		deleted     bool
+	}{
+		{"web-pending", "", steered, true},
+		{"web-scheduled", "node-b", steered, false},
+		{"web-unsteered", "", nil, false},
+		{"web-steered-by-other", "", map[string]string{SteeredByAnnotation: "default/other"}, false},
+	}
+	for _, p := range pods {
+		pod := newTestPod(p.name, p.nodeName)
+		pod.ObjectMeta.UID = types.UID(p.name)
+		pod.ObjectMeta.Annotations = p.annotations
+		if err := cluster.client.Create(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := cluster.newRunner()
+	workload := k8s.Workload{Namespace: "default", Name: "web", Selector: labels.SelectorFromSet(labels.Set{"app": "web"})}
+	runner.deleteSteeredPendingPods(context.Background(), workload, observedPods)
+
+	for _, p := range pods {
+		err := cluster.client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: p.name}, &corev1.Pod{})
+		if deleted := apierrors.IsNotFound(err); deleted != p.deleted {
+			t.Errorf("Pod %s is deleted %t, want %t", p.name, deleted, p.deleted)
+		}
+	}
+}
+
+func TestRestoringSurgeClosesStatus(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeSurgeFirst)
+	ctx := context.Background()
+	deployment := appsv1.Deployment{}
+	if err := cluster.client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "web"}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	// the controller restarted in the middle of a surge
+	deployment.ObjectMeta.Annotations = map[string]string{k8s.SurgeReplicasAnnotation: "2"}
+	if err := cluster.client.Update(ctx, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	cluster.replicas = 3
+
+	if err := cluster.newRunner().runStrategies(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := cluster.getReplicas(); replicas != 2 {
+		t.Errorf("replicas are %d after the restore, expected 2", replicas)
+	}
+
+	baton := batonv1.Baton{}
+	if err := cluster.client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "baton"}, &baton); err != nil {
+		t.Fatal(err)
+	}
+	progressing := batonv1.FindCondition(baton.Status.Conditions, batonv1.ConditionProgressing)
+	if progressing == nil || progressing.Status != metav1.ConditionFalse {
+		t.Errorf("Progressing is %v after the run, want False", progressing)
+	}
+	if ready := batonv1.FindCondition(baton.Status.Conditions, batonv1.ConditionReady); ready == nil {
+		t.Error("Ready is not set after the run")
+	}
+}
+
+func TestRecordFailureCountsValidationFailures(t *testing.T) {
+	cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+	runner := cluster.newRunner()
+	counter := validationFailuresTotal.WithLabelValues("default", "baton")
+	// the counter is shared by the runs of the other tests
+	before := testutil.ToFloat64(counter)
+
+	cases := []struct {
+		conditionType batonv1.BatonConditionType
+		want          float64
+	}{
+		{batonv1.ConditionValidationFailed, 1},
+		{batonv1.ConditionDegraded, 1},
+		{batonv1.ConditionValidationFailed, 2},
+	}
+	for _, c := range cases {
+		_ = runner.recordFailure(context.Background(), c.conditionType, "Test", errors.New("failed"))
+		if got := testutil.ToFloat64(counter) - before; got != c.want {
+			t.Errorf("baton_validation_failures_total increased by %v after a %s failure, want %v", got, c.conditionType, c.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	batonv1 "trsnium.com/baton/api/v1"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+// migrationKindSkew moves pods between the domains of a strategy's topologyKey
+const migrationKindSkew = "Skew"
+
+// planSkewMigrations plans to move pods of each strategy with a topologyKey off its most crowded domains,
+// until the difference between its domains is within maxSkew
+func (r *BatonStrategiesyRunner) planSkewMigrations(
+	ctx context.Context,
+	strategies []batonv1.Strategy,
+	workload k8s.Workload,
+) []migration {
+	migrations := []migration{}
+	for i, strategy := range strategies {
+		if strategy.TopologyKey == "" {
+			continue
+		}
+
+		nodes, err := strategy.GetMatchNodes(ctx, r.client)
+		if err != nil {
+			r.logger.Error(err, "failed to list Nodes")
+			continue
+		}
+		nodes = r.excludeTerminatingNodes(nodes)
+
+		pods, err := strategy.GetPodsScheduledNodes(ctx, r.client, workload)
+		if err != nil {
+			r.logger.Error(err, "failed to list Pods")
+			continue
+		}
+		if workload.IsStatefulSet() {
+			k8s.SortPodsByOrdinal(pods, false)
+		}
+
+		podsPerDomain := strategy.GetPodsPerDomain(nodes, pods)
+		if len(podsPerDomain) < 2 {
+			continue
+		}
+
+		// a pod is moved from the most to the least crowded domain at a time, so the skew shrinks on every move
+		podsToMove := map[string]int{}
+		for {
+			mostCrowded, leastCrowded := getMostAndLeastCrowdedDomains(podsPerDomain)
+			if podsPerDomain[mostCrowded]-podsPerDomain[leastCrowded] <= strategy.GetMaxSkew() {
+				break
+			}
+			podsPerDomain[mostCrowded]--
+			podsPerDomain[leastCrowded]++
+			podsToMove[mostCrowded]++
+		}
+		if len(podsToMove) == 0 {
+			continue
+		}
+
+		nodeDomains := getNodeDomains(nodes, strategy.TopologyKey)
+		sourceDomains := []string{}
+		for domain := range podsToMove {
+			sourceDomains = append(sourceDomains, domain)
+		}
+		sort.Strings(sourceDomains)
+		victims := []corev1.Pod{}
+		for _, domain := range sourceDomains {
+			domainPods := k8s.FilterPods(pods, func(p corev1.Pod) bool {
+				return nodeDomains[p.Spec.NodeName] == domain
+			})
+			victims = append(victims, domainPods[len(domainPods)-podsToMove[domain]:]...)
+		}
+
+		// the replacements may land in any domain the pods are not moved off
+		cordonNodes := k8s.FilterNodes(nodes, func(n corev1.Node) bool {
+			_, isSource := podsToMove[nodeDomains[n.ObjectMeta.Name]]
+			return isSource
+		})
+		// cordons and taints only keep the replacements off the nodes they are put on, so the nodes of the
+		// other strategies are steered off as well, or the replacements land there and the next run moves them back
+		if r.baton.Spec.SteeringMethod != batonv1.SteeringMethodNodeAffinity {
+			otherStrategies := []batonv1.Strategy{}
+			for j, s := range strategies {
+				if i != j {
+					otherStrategies = append(otherStrategies, s)
+				}
+			}
+			otherNodes, err := batonv1.GetStrategiesMatchNodes(ctx, r.client, otherStrategies)
+			if err != nil {
+				r.logger.Error(err, "failed to list Nodes")
+				continue
+			}
+			cordonNodes = append(cordonNodes, otherNodes...)
+		}
+		targetNodes := k8s.FilterNodes(excludeNodes(nodes, cordonNodes), func(n corev1.Node) bool {
+			_, hasDomain := nodeDomains[n.ObjectMeta.Name]
+			return hasDomain
+		})
+		targetDomains := []string{}
+		for domain := range strategy.GetPodsPerDomain(targetNodes, nil) {
+			targetDomains = append(targetDomains, domain)
+		}
+		sort.Strings(targetDomains)
+
+		migrations = append(migrations, migration{
+			strategy:         strategy,
+			kind:             migrationKindSkew,
+			cordonNodes:      cordonNodes,
+			pods:             victims,
+			targetNodes:      targetNodes,
+			targetStrategies: []batonv1.Strategy{strategy.ForDomains(targetDomains)},
+		})
+	}
+	return migrations
+}
+
+// chooseSpreadVictims returns count of the pods, taking each from the domain with the most pods left,
+// so that the pods left behind stay spread across the domains
+func chooseSpreadVictims(pods []corev1.Pod, nodeDomains map[string]string, count int) []corev1.Pod {
+	podsPerDomain := map[string][]corev1.Pod{}
+	for _, pod := range pods {
+		domain := nodeDomains[pod.Spec.NodeName]
+		podsPerDomain[domain] = append(podsPerDomain[domain], pod)
+	}
+
+	victims := []corev1.Pod{}
+	for len(victims) < count {
+		counts := map[string]int32{}
+		for domain, domainPods := range podsPerDomain {
+			if len(domainPods) != 0 {
+				counts[domain] = int32(len(domainPods))
+			}
+		}
+		if len(counts) == 0 {
+			break
+		}
+
+		// the pods at the end of a domain go first, as with the pods beyond keepPods of a strategy
+		domain, _ := getMostAndLeastCrowdedDomains(counts)
+		domainPods := podsPerDomain[domain]
+		victims = append(victims, domainPods[len(domainPods)-1])
+		podsPerDomain[domain] = domainPods[:len(domainPods)-1]
+	}
+	return victims
+}
+
+// getMostAndLeastCrowdedDomains breaks ties by the names of the domains, so that the choice is deterministic
+func getMostAndLeastCrowdedDomains(podsPerDomain map[string]int32) (string, string) {
+	domains := []string{}
+	for domain := range podsPerDomain {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	mostCrowded, leastCrowded := domains[0], domains[0]
+	for _, domain := range domains[1:] {
+		if podsPerDomain[domain] > podsPerDomain[mostCrowded] {
+			mostCrowded = domain
+		}
+		if podsPerDomain[domain] < podsPerDomain[leastCrowded] {
+			leastCrowded = domain
+		}
+	}
+	return mostCrowded, leastCrowded
+}
+
+// getNodeDomains maps the names of the nodes to the values of their topology key label
+func getNodeDomains(nodes []corev1.Node, topologyKey string) map[string]string {
+	nodeDomains := map[string]string{}
+	for _, node := range nodes {
+		if domain, ok := node.ObjectMeta.Labels[topologyKey]; ok {
+			nodeDomains[node.ObjectMeta.Name] = domain
+		}
+	}
+	return nodeDomains
+}
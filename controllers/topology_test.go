@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	batonv1 "trsnium.com/baton/api/v1"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+func getPodNames(pods []corev1.Pod) []string {
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.ObjectMeta.Name)
+	}
+	return names
+}
+
+func TestGetMostAndLeastCrowdedDomains(t *testing.T) {
+	cases := []struct {
+		name             string
+		podsPerDomain    map[string]int32
+		wantMostCrowded  string
+		wantLeastCrowded string
+	}{
+		{"uneven domains", map[string]int32{"a": 1, "b": 3, "c": 2}, "b", "a"},
+		{"domain without pods", map[string]int32{"a": 2, "b": 0, "c": 1}, "a", "b"},
+		{"ties go to the first domain by name", map[string]int32{"c": 2, "b": 2, "a": 2}, "a", "a"},
+		{"single domain", map[string]int32{"a": 4}, "a", "a"},
+	}
+	for _, c := range cases {
+		mostCrowded, leastCrowded := getMostAndLeastCrowdedDomains(c.podsPerDomain)
+		if mostCrowded != c.wantMostCrowded || leastCrowded != c.wantLeastCrowded {
+			t.Errorf("%s: got (%s, %s), want (%s, %s)", c.name, mostCrowded, leastCrowded, c.wantMostCrowded, c.wantLeastCrowded)
+		}
+	}
+}
+
+func TestChooseSpreadVictims(t *testing.T) {
+	nodeDomains := map[string]string{"node-1": "zone-1", "node-2": "zone-2", "node-3": "zone-3"}
+	pods := []corev1.Pod{
+		*newTestPod("web-1", "node-1"),
+		*newTestPod("web-2", "node-1"),
+		*newTestPod("web-3", "node-1"),
+		*newTestPod("web-4", "node-2"),
+		*newTestPod("web-5", "node-2"),
+		*newTestPod("web-6", "node-3"),
+	}
+
+	cases := []struct {
+		name  string
+		count int
+		want  []string
+	}{
+		{"the most crowded domain first", 1, []string{"web-3"}},
+		{"evens out the domains", 3, []string{"web-3", "web-2", "web-5"}},
+		{"no more than the pods", 10, []string{"web-3", "web-2", "web-5", "web-1", "web-4", "web-6"}},
+		{"none", 0, []string{}},
+	}
+	for _, c := range cases {
+		if got := getPodNames(chooseSpreadVictims(pods, nodeDomains, c.count)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: chose %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPlanSkewMigrations(t *testing.T) {
+	maxSkew := func(n int32) *int32 {
+		return &n
+	}
+
+	cases := []struct {
+		name        string
+		maxSkew     *int32
+		podsPerNode map[string]int
+		wantVictims []string
+		wantTargets []string
+	}{
+		{
+			name:        "uneven domains with one without pods",
+			podsPerNode: map[string]int{"zone-1-node": 5, "zone-2-node": 1},
+			wantVictims: []string{"zone-1-node-3", "zone-1-node-4", "zone-1-node-5"},
+			wantTargets: []string{"zone-2-node", "zone-3-node"},
+		},
+		{
+			name:        "maxSkew above 1 moves fewer pods",
+			maxSkew:     maxSkew(2),
+			podsPerNode: map[string]int{"zone-1-node": 5, "zone-2-node": 1},
+			wantVictims: []string{"zone-1-node-4", "zone-1-node-5"},
+			wantTargets: []string{"zone-2-node", "zone-3-node"},
+		},
+		{
+			name:        "domains within maxSkew",
+			maxSkew:     maxSkew(3),
+			podsPerNode: map[string]int{"zone-1-node": 3, "zone-2-node": 1},
+		},
+	}
+	for _, c := range cases {
+		cluster := newTestCluster(t, batonv1.MigrationModeDelete)
+		ctx := context.Background()
+		// node-a of the cluster carries pods but no topology label, so it belongs to no domain
+		for _, zone := range []string{"zone-1", "zone-2", "zone-3"} {
+			node := newTestNode(zone+"-node", "a")
+			node.ObjectMeta.Labels["zone"] = zone
+			if err := cluster.client.Create(ctx, node); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for nodeName, count := range c.podsPerNode {
+			for i := 1; i <= count; i++ {
+				pod := newTestPod(fmt.Sprintf("%s-%d", nodeName, i), nodeName)
+				if err := cluster.client.Create(ctx, pod); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		cluster.baton.Spec.Strategies[0].TopologyKey = "zone"
+		cluster.baton.Spec.Strategies[0].MaxSkew = c.maxSkew
+		runner := cluster.newRunner()
+		workload := k8s.Workload{Namespace: "default", Name: "web", Selector: labels.SelectorFromSet(labels.Set{"app": "web"})}
+
+		migrations := runner.planSkewMigrations(ctx, cluster.baton.Spec.Strategies, workload)
+		if c.wantVictims == nil {
+			if len(migrations) != 0 {
+				t.Errorf("%s: planned %d migrations, want none", c.name, len(migrations))
+			}
+			continue
+		}
+		if len(migrations) != 1 {
+			t.Fatalf("%s: planned %d migrations, want 1", c.name, len(migrations))
+		}
+		m := migrations[0]
+		if got := getPodNames(m.pods); !reflect.DeepEqual(got, c.wantVictims) {
+			t.Errorf("%s: moves %v, want %v", c.name, got, c.wantVictims)
+		}
+		targets := []string{}
+		for _, node := range m.targetNodes {
+			targets = append(targets, node.ObjectMeta.Name)
+		}
+		if !reflect.DeepEqual(targets, c.wantTargets) {
+			t.Errorf("%s: targets %v, want %v", c.name, targets, c.wantTargets)
+		}
+		if !containsNode(m.cordonNodes, "zone-1-node") || !containsNode(m.cordonNodes, "node-b") {
+			t.Errorf("%s: steers off %v, want the source domain and the nodes of the other strategy", c.name, m.cordonNodes)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+// SetupWithManager lets the runners react to changes of their pods and nodes
+// through the informers shared with the rest of the manager.
+// The periodic run every intervalSec stays as a resync fallback.
+func (r *BatonStrategiesRunnerManager) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	podInformer, err := mgr.GetCache().GetInformer(ctx, &corev1.Pod{})
+	if err != nil {
+		return err
+	}
+	podInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: r.triggerByPod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if isPodChanged(oldObj, newObj) {
+				r.triggerByPod(newObj)
+			}
+		},
+		DeleteFunc: r.triggerByPod,
+	})
+
+	nodeInformer, err := mgr.GetCache().GetInformer(ctx, &corev1.Node{})
+	if err != nil {
+		return err
+	}
+	nodeInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: r.triggerByNode,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if isNodeChanged(oldObj, newObj) {
+				r.triggerByNode(newObj)
+			}
+			r.evacuateByNode(oldObj, newObj)
+		},
+		DeleteFunc: r.triggerByNode,
+	})
+	return nil
+}
+
+func (r *BatonStrategiesRunnerManager) triggerByPod(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	r.triggerRunners(func(runner *BatonStrategiesyRunner) bool {
+		return runner.IsWatchingPod(pod)
+	})
+}
+
+func (r *BatonStrategiesRunnerManager) triggerByNode(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	r.triggerRunners(func(runner *BatonStrategiesyRunner) bool {
+		return runner.IsWatchingNode(node)
+	})
+}
+
+// evacuateByNode runs the runners right away when one of their nodes starts signalling its termination
+func (r *BatonStrategiesRunnerManager) evacuateByNode(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, batonStrategiesRunner := range r.batonStrategiesRunnerMap {
+		if !batonStrategiesRunner.IsWatchingNode(newNode) {
+			continue
+		}
+		if !batonStrategiesRunner.IsNodeTerminating(oldNode) && batonStrategiesRunner.IsNodeTerminating(newNode) {
+			batonStrategiesRunner.Evacuate()
+		}
+	}
+}
+
+// isPodChanged ignores the updates which do not affect where the pod runs or whether it is ready
+func isPodChanged(oldObj, newObj interface{}) bool {
+	oldPod, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return true
+	}
+	newPod, ok := newObj.(*corev1.Pod)
+	if !ok {
+		return true
+	}
+
+	return oldPod.Spec.NodeName != newPod.Spec.NodeName ||
+		oldPod.Status.Phase != newPod.Status.Phase ||
+		!oldPod.ObjectMeta.DeletionTimestamp.Equal(newPod.ObjectMeta.DeletionTimestamp) ||
+		k8s.IsPodReady(*oldPod) != k8s.IsPodReady(*newPod)
+}
+
+// isNodeChanged ignores the updates which do not affect whether pods can be scheduled on the node,
+// such as heartbeats, and the cordons and steering taints of Batons, which the runners make themselves
+func isNodeChanged(oldObj, newObj interface{}) bool {
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return true
+	}
+	newNode, ok := newObj.(*corev1.Node)
+	if !ok {
+		return true
+	}
+
+	return !reflect.DeepEqual(oldNode.ObjectMeta.Labels, newNode.ObjectMeta.Labels) ||
+		isCordonedByOthers(*oldNode) != isCordonedByOthers(*newNode) ||
+		!reflect.DeepEqual(getForeignTaints(*oldNode), getForeignTaints(*newNode)) ||
+		!oldNode.ObjectMeta.DeletionTimestamp.Equal(newNode.ObjectMeta.DeletionTimestamp)
+}
+
+// isCordonedByOthers reports whether the node is cordoned by someone other than Baton
+func isCordonedByOthers(node corev1.Node) bool {
+	return node.Spec.Unschedulable && len(k8s.GetCordonOwners(node)) == 0
+}
+
+// getForeignTaints returns the taints of the node except the steering taints of Batons
+func getForeignTaints(node corev1.Node) []corev1.Taint {
+	taints := []corev1.Taint{}
+	for _, taint := range node.Spec.Taints {
+		if !strings.HasPrefix(taint.Key, k8s.SteeringTaintKeyPrefix) {
+			taints = append(taints, taint)
+		}
+	}
+	return taints
+}
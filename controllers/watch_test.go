@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8s "trsnium.com/baton/controllers/kubernetes"
+)
+
+func TestIsNodeChanged(t *testing.T) {
+	node := newTestNode("node-a", "a")
+	cordonedByBaton := node.DeepCopy()
+	cordonedByBaton.Spec.Unschedulable = true
+	cordonedByBaton.ObjectMeta.Annotations = map[string]string{k8s.CordonedByAnnotation: "default/baton"}
+	cordonedByAdmin := node.DeepCopy()
+	cordonedByAdmin.Spec.Unschedulable = true
+	steered := node.DeepCopy()
+	steered.Spec.Taints = []corev1.Taint{{Key: k8s.SteeringTaintKeyPrefix + "default.baton", Effect: corev1.TaintEffectNoSchedule}}
+	tainted := node.DeepCopy()
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	relabeled := node.DeepCopy()
+	relabeled.ObjectMeta.Labels["pool"] = "b"
+	heartbeat := node.DeepCopy()
+	heartbeat.Status.Conditions[0].Reason = "KubeletReady"
+
+	cases := []struct {
+		name string
+		old  *corev1.Node
+		new  *corev1.Node
+		want bool
+	}{
+		{"heartbeat", node, heartbeat, false},
+		{"cordoned by Baton", node, cordonedByBaton, false},
+		{"uncordoned by Baton", cordonedByBaton, node, false},
+		{"steering taint added", node, steered, false},
+		{"steering taint removed", steered, node, false},
+		{"cordoned by an administrator", node, cordonedByAdmin, true},
+		{"cordon handed over from Baton to an administrator", cordonedByBaton, cordonedByAdmin, true},
+		{"other taint added", node, tainted, true},
+		{"label changed", node, relabeled, true},
+	}
+	for _, c := range cases {
+		if got := isNodeChanged(c.old, c.new); got != c.want {
+			t.Errorf("%s: isNodeChanged = %t, want %t", c.name, got, c.want)
+		}
+	}
+}
@@ -20,10 +20,14 @@ import (
 	"os"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/scale"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	batonv1 "trsnium.com/baton/api/v1"
 	"trsnium.com/baton/controllers"
 	// +kubebuilder:scaffold:imports
@@ -44,9 +48,12 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Plan the migrations of every Baton without cordoning nodes or evicting pods, as if spec.dryRun were set.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
@@ -65,16 +72,57 @@ func main() {
 	}
 
 	client := mgr.GetClient()
+	clientset := kubernetes.NewForConfigOrDie(mgr.GetConfig())
+	recorder := mgr.GetEventRecorderFor("baton-controller")
+	scales, err := scale.NewForConfig(
+		mgr.GetConfig(),
+		mgr.GetRESTMapper(),
+		dynamic.LegacyAPIPathResolverFunc,
+		scale.NewDiscoveryScaleKindResolver(clientset.Discovery()),
+	)
+	if err != nil {
+		setupLog.Error(err, "unable to create scale client")
+		os.Exit(1)
+	}
 	logger := ctrl.Log.WithName("controllers").WithName("Baton")
+	batonStrategiesRunnerManager := controllers.NewBatonStrategiesyRunnerManager(
+		client,
+		clientset,
+		scales,
+		mgr.GetRESTMapper(),
+		recorder,
+		dryRun,
+		logger,
+	)
 	if err = (&controllers.BatonReconciler{
 		Client:                       client,
 		Log:                          logger,
 		Scheme:                       mgr.GetScheme(),
-		BatonStrategiesRunnerManager: controllers.NewBatonStrategiesyRunnerManager(client, logger),
+		Recorder:                     recorder,
+		BatonStrategiesRunnerManager: batonStrategiesRunnerManager,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Baton")
 		os.Exit(1)
 	}
+	if err = batonStrategiesRunnerManager.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to watch pods and nodes", "controller", "Baton")
+		os.Exit(1)
+	}
+	// the runners are stopped gracefully when the manager shuts down
+	if err = mgr.Add(batonStrategiesRunnerManager); err != nil {
+		setupLog.Error(err, "unable to add runner manager", "controller", "Baton")
+		os.Exit(1)
+	}
+	// ENABLE_WEBHOOKS=false runs the controller without the webhook server, e.g. locally without certificates
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&batonv1.Baton{}).SetupWebhookWithManager(mgr, scales); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Baton")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register("/mutate-v1-pod", &webhook.Admission{
+			Handler: &controllers.PodSteerer{Client: mgr.GetClient()},
+		})
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")